@@ -10,9 +10,11 @@ import (
 	"syscall"
 
 	"go-project/internal/auth"
-	"go-project/internal/env"
+	"go-project/internal/blob"
+	"go-project/internal/cursor"
 	"go-project/internal/mailer"
 	"go-project/internal/ratelimiter"
+	"go-project/internal/realtime"
 	"go-project/internal/store"
 	"go-project/internal/store/cache"
 	"net/http"
@@ -35,6 +37,9 @@ type application struct {
 	authenticator auth.Aunthenticator
 	cacheStorage cache.Storage
 	ratelimiter ratelimiter.Limiter
+	cursor *cursor.Signer
+	events realtime.Broker
+	blobs blob.Store
 }
 
 type servConfig struct {
@@ -44,9 +49,59 @@ type servConfig struct {
 	apiURL string
 	mail mailConfig
 	frontendURL string
+	// allowedOrigin is the origin of the frontend, the only one allowed to
+	// make cross-origin requests and to open WebSockets
+	allowedOrigin string
 	auth authConfig
 	redis redisConfig
 	ratelimiter ratelimiter.Config
+	pagination paginationConfig
+	timeline timelineConfig
+	stream streamConfig
+	profile profileConfig
+	upload uploadConfig
+	scheduler schedulerConfig
+	retention retentionConfig
+}
+
+type retentionConfig struct{
+	// restoreWindow is how long deleted posts and accounts can be restored
+	restoreWindow time.Duration
+	// period is how long deleted posts and accounts are kept before they
+	// are purged
+	period time.Duration
+	// unattachedMedia is how long uploads that are not attached to any post
+	// are kept
+	unattachedMedia time.Duration
+	purgeInterval time.Duration
+}
+
+type schedulerConfig struct{
+	interval time.Duration
+	batchSize int
+}
+
+type uploadConfig struct{
+	dir string
+	baseURL string
+	maxSize int64
+	maxPixels int
+}
+
+type profileConfig struct{
+	usernameCooldown time.Duration
+}
+
+type streamConfig struct{
+	backlog int
+}
+
+type timelineConfig struct{
+	popularThreshold int
+}
+
+type paginationConfig struct{
+	cursorSecret string
 }
 
 type redisConfig struct{
@@ -63,6 +118,7 @@ type authConfig struct{
 type tokenConfig struct{
 	secret string
 	exp time.Duration
+	refreshExp time.Duration
 	iss string
 }
 
@@ -72,6 +128,7 @@ type authBasicConfig struct{
 }
 type mailConfig struct{
 	mailExp time.Duration
+	passwordResetExp time.Duration
 	sendGrid sendGrid 
 	fromEmail string
 }
@@ -96,10 +153,10 @@ func (app *application) mount() http.Handler {
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Logger)
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{env.GetString("CORS_ALLOWED_ORIGIN", "http://localhost:3000")},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Content-Type", "Authorization", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedOrigins:   []string{app.config.allowedOrigin},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "Authorization", "X-CSRF-Token", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: false,
 		MaxAge: 300,
 	}))
@@ -110,8 +167,8 @@ func (app *application) mount() http.Handler {
  
 	// Set a timeout value on the request context (ctx), that will signal
 	// through ctx.Done() that the request has timed out and further
-	// processing should be stopped.
-	r.Use(middleware.Timeout(60 * time.Second))
+	// processing should be stopped. Event streams are meant to stay open.
+	r.Use(skipForStreams(middleware.Timeout(60 * time.Second)))
 
 	r.Route("/v1", func(r chi.Router) {
 		r.With(app.BasicMiddlewareAuth()).Get("/health", app.healthCheckHandler)
@@ -121,27 +178,138 @@ func (app *application) mount() http.Handler {
 		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 
+		r.With(app.AuthTokenMiddleware).Get("/search", app.searchHandler)
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/trending", app.getTrendingTagsHandler)
+			r.Get("/following", app.getFollowedTagsHandler)
+			r.Get("/{tag}/posts", app.getTagPostsHandler)
+			r.Put("/{tag}/follow", app.followTagHandler)
+			r.Delete("/{tag}/follow", app.unfollowTagHandler)
+		})
+
+		r.Route("/media", func(r chi.Router) {
+			r.Get("/*", app.getMediaHandler)
+			r.With(app.AuthTokenMiddleware).Post("/", app.uploadMediaHandler)
+		})
+
 		r.Route("/posts", func(r chi.Router){
 			r.Use(app.AuthTokenMiddleware)
 			r.Post("/", app.createPosts)
+
+			// deleted posts are not found by postsContextMiddleware
+			r.With(app.deletedPostsContextMiddleware).Post("/{postID}/restore", app.RoleBasedAuthMiddleware("moderator", app.restorePostHandler))
 		
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postsContextMiddleware)
 				r.Get("/", app.getPosts)
 				r.Delete("/", app.RoleBasedAuthMiddleware("moderator", app.deletePost))
 				r.Patch("/", app.RoleBasedAuthMiddleware("admin", app.updatePost))
+				r.Delete("/schedule", app.RoleBasedAuthMiddleware("admin", app.cancelScheduledPostHandler))
+
+				// a repost stands for the reposted post on these routes
+				r.Group(func(r chi.Router) {
+					r.Use(app.originalPostMiddleware)
+					r.Get("/revisions", app.RoleBasedAuthMiddleware("moderator", app.getPostRevisionsHandler))
+					r.Post("/repost", app.repostHandler)
+					r.Delete("/repost", app.undoRepostHandler)
+					r.Put("/reactions/{kind}", app.addReactionHandler)
+					r.Delete("/reactions/{kind}", app.removeReactionHandler)
+					r.Put("/bookmark", app.bookmarkPostHandler)
+					r.Delete("/bookmark", app.unbookmarkPostHandler)
+
+					r.Route("/comments", func(r chi.Router) {
+						r.Post("/", app.createCommentHandler)
+
+						r.Route("/{commentID}", func(r chi.Router) {
+							r.Use(app.commentsContextMiddleware)
+							r.Patch("/", app.RoleBasedAuthMiddleware("admin", app.updateCommentHandler))
+							r.Delete("/", app.RoleBasedAuthMiddleware("moderator", app.deleteCommentHandler))
+							r.Put("/reactions/{kind}", app.addReactionHandler)
+							r.Delete("/reactions/{kind}", app.removeReactionHandler)
+						})
+					})
+				})
+			})
+		})
+
+		r.Route("/stream", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.streamHandler)
+			r.Get("/ws", app.streamWebSocketHandler)
+		})
+
+		r.Route("/conversations", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.getConversationsHandler)
+			r.Post("/", app.createConversationHandler)
+			r.Get("/unread-count", app.getUnreadConversationsCountHandler)
+
+			r.Route("/{conversationID}", func(r chi.Router) {
+				r.Use(app.conversationsContextMiddleware)
+				r.Get("/", app.getConversationHandler)
+				r.Delete("/", app.leaveConversationHandler)
+				r.Get("/messages", app.getMessagesHandler)
+				r.Post("/messages", app.sendMessageHandler)
+				r.Put("/read", app.markMessagesReadHandler)
+				r.Put("/mute", app.muteConversationHandler)
+				r.Delete("/mute", app.unmuteConversationHandler)
+				r.Post("/accept", app.acceptConversationHandler)
 			})
 		})
 
+		r.Route("/notifications", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.getNotificationsHandler)
+			r.Get("/unread-count", app.getUnreadNotificationsCountHandler)
+			r.Post("/read", app.markNotificationsReadHandler)
+			r.Put("/{notificationID}/read", app.markNotificationReadHandler)
+			r.Get("/preferences", app.getNotificationPreferencesHandler)
+			r.Patch("/preferences", app.updateNotificationPreferencesHandler)
+		})
+
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activation/{token}", app.userActivationHandler)
 
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Patch("/", app.updateMeHandler)
+				r.Delete("/", app.deleteMeHandler)
+				r.Put("/avatar", app.updateAvatarHandler)
+				r.Delete("/avatar", app.deleteAvatarHandler)
+				r.Get("/drafts", app.getDraftsHandler)
+				r.Get("/scheduled", app.getScheduledPostsHandler)
+
+				r.Route("/follow-requests", func(r chi.Router) {
+					r.Get("/", app.getFollowRequestsHandler)
+					r.Put("/{userID}", app.approveFollowRequestHandler)
+					r.Delete("/{userID}", app.denyFollowRequestHandler)
+				})
+
+				r.Route("/bookmarks", func(r chi.Router) {
+					r.Get("/", app.getBookmarksHandler)
+					r.Get("/collections", app.getBookmarkCollectionsHandler)
+					r.Post("/collections", app.createBookmarkCollectionHandler)
+					r.Delete("/collections/{collectionID}", app.deleteBookmarkCollectionHandler)
+				})
+			})
+
+			r.With(app.AuthTokenMiddleware).Get("/by-username/{username}", app.getUserByUsernameHandler)
+
 			r.Route("/{userID}", func(r chi.Router) {
-				// r.Use(app.AuthTokenMiddleware)
+				r.Use(app.AuthTokenMiddleware)
 
 				r.Get("/", app.getUserHandler)
+				r.Get("/followers", app.getFollowersHandler)
+				r.Get("/following", app.getFollowingHandler)
+				r.Post("/restore", app.restoreUserHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Put("/block", app.blockUserHandler)
+				r.Delete("/block", app.unblockUserHandler)
+				r.Put("/mute", app.muteUserHandler)
+				r.Delete("/mute", app.unmuteUserHandler)
 			})
 			
 			
@@ -157,6 +325,10 @@ func (app *application) mount() http.Handler {
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.userRegisterHandler)
 			r.Post("/token", app.getUserTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.With(app.AuthTokenMiddleware).Post("/logout", app.logoutHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Post("/password/reset", app.resetPasswordHandler)
 		})
 	})
 
@@ -180,6 +352,12 @@ func (app *application) run(mux http.Handler) error {
 
 	shutdown := make(chan error)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go app.runScheduler(ctx)
+	go app.runPurger(ctx)
+
 	go func ()  {
 		
 		quit := make(chan os.Signal, 1)
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"go-project/internal/mailer"
@@ -15,7 +17,7 @@ import (
 )
 
 type UserPostPayload struct {
-	Username string `json:"username" validate:"required,max=100"`
+	Username string `json:"username" validate:"required,username"`
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=6,max=72"`
 }
@@ -30,6 +32,29 @@ type CreateUserTokenPayload struct {
 	Password string `json:"password" validate:"required,min=6,max=72"`
 }
 
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutPayload struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6,max=72"`
+}
+
+type AuthTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
 // User Login godoc
 //
 //	@Summary		Register a user
@@ -151,12 +176,12 @@ func (app *application) userActivationHandler(w http.ResponseWriter, r *http.Req
 // getUserTokenHandler godoc
 //
 //	@Summary		Creates a token
-//	@Description	Creates a token for a user
+//	@Description	Creates an access token and a refresh token for a user
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		200		{string}	string					"Token"
+//	@Success		201		{object}	AuthTokens				"Tokens"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
 //	@Failure		500		{object}	error
@@ -181,7 +206,7 @@ func (app *application) getUserTokenHandler(w http.ResponseWriter, r *http.Reque
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
-			app.internalServerError(w, r, err)
+			app.unAuthorizedError(w, r, err)
 			return
 		default:
 			app.internalServerError(w, r, err)
@@ -189,21 +214,299 @@ func (app *application) getUserTokenHandler(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
-		"iss": app.config.auth.token.iss,
-		"aud": app.config.auth.token.iss, 
-	}	
-	token, err := app.authenticator.GenerateToken(claims)	
+	if err := user.Password.Compare(userPayload.Password); err != nil {
+		app.unAuthorizedError(w, r, err)
+		return
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Tokens.Create(ctx, user.ID, refreshToken, app.config.auth.token.refreshExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.generateTokens(user.ID, refreshToken)
 	if err != nil{
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, token); err != nil {
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access token and a new refresh token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		201		{object}	AuthTokens			"Tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	rt, err := app.store.Tokens.Rotate(ctx, payload.RefreshToken, refreshToken, app.config.auth.token.refreshExp)
+	if err != nil {
+		switch err {
+		case store.ErrTokenReused:
+			app.logger.Warnw("refresh token reuse detected, token family revoked", "method", r.Method, "url", r.URL.Path)
+			app.unAuthorizedError(w, r, err)
+		case store.ErrNotFound:
+			app.unAuthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the user may have been deactivated since the family was issued
+	if _, err := app.getUser(ctx, rt.UserID); err != nil {
+		app.unAuthorizedError(w, r, err)
+		return
+	}
+
+	tokens, err := app.generateTokens(rt.UserID, refreshToken)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Logs a user out
+//	@Description	Revokes the access token of the request and, when given, the refresh token family
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		LogoutPayload	false	"Refresh token"
+//	@Success		204		{string}	string			"Logged out"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var payload LogoutPayload
+
+	if r.ContentLength != 0 {
+		if err := readJSON(w, r, &payload); err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	user := getUserCtx(r)
+	claims := getClaimsCtx(r)
+
+	if payload.RefreshToken != "" {
+		if err := app.store.Tokens.RevokeFamily(ctx, user.ID, payload.RefreshToken); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
 		app.internalServerError(w, r, err)
+		return
 	}
+
+	jti, _ := claims["jti"].(string)
+	if err := app.denyToken(ctx, jti, exp.Time); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// forgotPasswordHandler godoc
+//
+//	@Summary		Requests a password reset
+//	@Description	Emails a single use password reset link to the user. The response does not reveal whether the email is registered.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"User email"
+//	@Success		202		{string}	string					"Reset link sent"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			// answer exactly as for a known email so accounts cannot be enumerated
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	token := uuid.New().String()
+
+	hash := sha256.Sum256([]byte(token))
+	hashedToken := hex.EncodeToString(hash[:])
+
+	if err := app.store.Users.CreatePasswordReset(ctx, user.ID, hashedToken, app.config.mail.passwordResetExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username  string
+		ResetURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetURL:  fmt.Sprintf("%s/password/reset/%s", app.config.frontendURL, token),
+		ExpiresIn: app.config.mail.passwordResetExp.String(),
+	}
+
+	status, err := app.mailer.Send(mailer.PasswordResetTemp, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending password reset email", "error", err)
+	} else {
+		app.logger.Infow("Email has been sent successfully", "status code:", status)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Resets a password
+//	@Description	Sets a new password using a password reset token and ends all existing sessions of the user
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		204		{string}	string					"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/reset [post]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	var password store.Password
+	if err := password.Set(payload.Password); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	userID, err := app.store.Users.ResetPassword(ctx, payload.Token, &password)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the cached user does not know that its access tokens were revoked
+	app.invalidateUser(ctx, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// generateTokens signs a short lived access token and pairs it with the
+// already stored refresh token.
+func (app *application) generateTokens(userID int64, refreshToken string) (*AuthTokens, error) {
+	now := time.Now()
+	exp := now.Add(app.config.auth.token.exp)
+
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"exp": exp.Unix(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.iss,
+		"jti": uuid.New().String(),
+	}
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    exp.Unix(),
+	}, nil
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
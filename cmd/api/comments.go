@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go-project/internal/realtime"
+	"go-project/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
+type CreateCommentPayload struct {
+	Content  string `json:"content" validate:"required,max=1000"`
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gte=1"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// CreateComment godoc
+//
+//	@Summary		Creates a comment
+//	@Description	Creates a comment on a post, or a reply when parent_id is set
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int						true	"Post ID"
+//	@Param			payload	body		CreateCommentPayload	true	"Comment payload"
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateCommentPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	post := getPostFromCtx(r)
+	user := getUserCtx(r)
+
+	var parent *store.Comment
+	if payload.ParentID != nil {
+		var err error
+		parent, err = app.store.Comments.GetbyID(ctx, *payload.ParentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequest(w, r, errors.New("parent comment does not exist"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if int64(parent.PostID) != post.ID {
+			app.badRequest(w, r, errors.New("parent comment belongs to another post"))
+			return
+		}
+	}
+
+	comment := &store.Comment{
+		PostID:      int(post.ID),
+		UserID:      int(user.ID),
+		ParentID:    payload.ParentID,
+		Content:     payload.Content,
+		Reactions:   map[string]int{},
+		ReactedByMe: []string{},
+		Mentions:    extractMentions(payload.Content),
+		Replies:     []store.Comment{},
+	}
+
+	if err := app.store.Comments.Create(ctx, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.notifyComment(post, parent, comment)
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Updates a comment
+//	@Description	Updates the content of a comment
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int						true	"Post ID"
+//	@Param			commentID	path		int						true	"Comment ID"
+//	@Param			payload		body		UpdateCommentPayload	true	"Comment payload"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	var payload UpdateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	previous := comment.Mentions
+	comment.Content = payload.Content
+	comment.Mentions = extractMentions(comment.Content)
+
+	if err := app.store.Comments.UpdatebyID(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	go app.notifyMentions(store.Notification{
+		ActorID:   int64(comment.UserID),
+		PostID:    &getPostFromCtx(r).ID,
+		CommentID: &comment.ID,
+	}, comment.Mentions, previous)
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Deletes a comment and all of its replies
+//	@Tags			comments
+//	@Produce		json
+//	@Param			postID		path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		204			{object}	string
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	if err := app.store.Comments.DeletebyID(r.Context(), comment.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifyComment notifies the author of the post about a new comment, the
+// author of the parent comment about a reply and the mentioned users, and
+// streams the comment to both authors unless they are blocked.
+func (app *application) notifyComment(post *store.Posts, parent *store.Comment, comment *store.Comment) {
+	n := store.Notification{
+		ActorID:   int64(comment.UserID),
+		PostID:    &post.ID,
+		CommentID: &comment.ID,
+	}
+
+	if parent != nil {
+		reply := n
+		reply.UserID = int64(parent.UserID)
+		reply.Kind = store.NotificationReply
+		go app.notify(reply)
+	}
+
+	if parent == nil || int64(parent.UserID) != post.UserID {
+		n.UserID = post.UserID
+		n.Kind = store.NotificationComment
+		go app.notify(n)
+	}
+
+	go app.notifyMentions(n, comment.Mentions, nil)
+
+	authors := []int64{post.UserID}
+	if parent != nil && int64(parent.UserID) != post.UserID {
+		authors = append(authors, int64(parent.UserID))
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+
+		// the author of the parent comment may have blocked the commenter
+		// on someone else's post
+		recipients := make([]int64, 0, len(authors))
+		for _, id := range authors {
+			blocked, err := app.store.Blocks.IsBlocked(ctx, id, int64(comment.UserID))
+			if err != nil {
+				app.logger.Errorw("error checking blocks", "user", id, "error", err)
+				continue
+			}
+			if !blocked {
+				recipients = append(recipients, id)
+			}
+		}
+
+		app.publish(ctx, realtime.EventCommentCreated, comment, recipients...)
+	}()
+}
+
+func (app *application) commentsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		comment, err := app.store.Comments.GetbyID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		// comments are addressed through their post, so a comment that
+		// belongs to another post does not exist under this URL
+		if int64(comment.PostID) != getPostFromCtx(r).ID {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(commentCtx).(*store.Comment)
+	return comment
+}
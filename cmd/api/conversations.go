@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go-project/internal/realtime"
+	"go-project/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type conversationKey string
+
+const conversationCtx conversationKey = "conversation"
+
+type CreateConversationPayload struct {
+	// MemberIDs are the users to talk to besides the creator; more than one
+	// starts a group conversation.
+	MemberIDs []int64 `json:"member_ids" validate:"required,min=1,max=9,dive,gte=1"`
+	Title     *string `json:"title" validate:"omitempty,max=100"`
+	Content   string  `json:"content" validate:"required,max=2000"`
+}
+
+type SendMessagePayload struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+type MarkMessagesReadPayload struct {
+	MessageID int64 `json:"message_id" validate:"required,gte=1"`
+}
+
+// CreateConversation godoc
+//
+//	@Summary		Starts a conversation
+//	@Description	Starts a conversation with its first message. Members who are not mutual followers of the creator receive a message request. Starting a one to one conversation that already exists adds the message to it.
+//	@Tags			conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateConversationPayload	true	"Conversation payload"
+//	@Success		201		{object}	store.Conversation
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [post]
+func (app *application) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateConversationPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserCtx(r)
+
+	memberIDs := make([]int64, 0, len(payload.MemberIDs))
+	seen := map[int64]bool{user.ID: true}
+	for _, id := range payload.MemberIDs {
+		if !seen[id] {
+			seen[id] = true
+			memberIDs = append(memberIDs, id)
+		}
+	}
+
+	if len(memberIDs) == 0 {
+		app.badRequest(w, r, errors.New("a conversation needs another member"))
+		return
+	}
+
+	conversationID, err := app.store.Conversations.FindDirect(ctx, user.ID, memberIDs[0])
+	if len(memberIDs) > 1 || errors.Is(err, store.ErrNotFound) {
+		conversation := &store.Conversation{
+			CreatedBy: &user.ID,
+			IsGroup:   len(memberIDs) > 1,
+			Title:     payload.Title,
+		}
+
+		err = app.store.Conversations.Create(ctx, conversation, memberIDs)
+		conversationID = conversation.ID
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrBlocked):
+			app.forbiddenResponse(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	message := &store.Message{
+		ConversationID: conversationID,
+		SenderID:       &user.ID,
+		Content:        payload.Content,
+	}
+
+	if err := app.store.Conversations.SendMessage(ctx, message); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrNotAccepted), errors.Is(err, store.ErrBlocked):
+			app.forbiddenResponse(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	conversation, err := app.store.Conversations.GetByID(ctx, conversationID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.publishMessage(conversation, message)
+
+	if err := app.jsonResponse(w, http.StatusCreated, conversation); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetConversations godoc
+//
+//	@Summary		Fetches the user's conversations
+//	@Description	Fetches the conversations of the user, most recently active first. With status=pending the message requests are returned instead.
+//	@Tags			conversations
+//	@Produce		json
+//	@Param			status	query		string	false	"Membership status"	Enums(accepted, pending)
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	[]store.Conversation
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [get]
+func (app *application) getConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	pq, err := store.PaginatedQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	pq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	status := store.MemberAccepted
+	if param := r.URL.Query().Get("status"); param != "" {
+		if param != store.MemberAccepted && param != store.MemberPending {
+			app.badRequest(w, r, errors.New("status must be accepted or pending"))
+			return
+		}
+		status = param
+	}
+
+	conversations, err := app.store.Conversations.List(r.Context(), getUserCtx(r).ID, status, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var next *store.Cursor
+	if len(conversations) > 0 {
+		last := conversations[len(conversations)-1]
+		next = nextCursor(len(conversations), pq.Limit, last.UpdatedAt, last.ID)
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, conversations, next); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUnreadConversationsCount godoc
+//
+//	@Summary		Counts unread conversations
+//	@Description	Returns the number of conversations with unread messages, muted conversations excluded
+//	@Tags			conversations
+//	@Produce		json
+//	@Success		200	{object}	map[string]int
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/unread-count [get]
+func (app *application) getUnreadConversationsCountHandler(w http.ResponseWriter, r *http.Request) {
+	count, err := app.store.Conversations.CountUnread(r.Context(), getUserCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, map[string]int{"unread_count": count}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetConversation godoc
+//
+//	@Summary		Fetches a conversation
+//	@Description	Fetches a conversation with its members and their read receipts
+//	@Tags			conversations
+//	@Produce		json
+//	@Param			conversationID	path		int	true	"Conversation ID"
+//	@Success		200				{object}	store.Conversation
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID} [get]
+func (app *application) getConversationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getConversationFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetMessages godoc
+//
+//	@Summary		Fetches the messages of a conversation
+//	@Description	Fetches the messages of a conversation, newest first
+//	@Tags			conversations
+//	@Produce		json
+//	@Param			conversationID	path		int		true	"Conversation ID"
+//	@Param			limit			query		int		false	"Limit"
+//	@Param			cursor			query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200				{object}	[]store.Message
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/messages [get]
+func (app *application) getMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	pq, err := store.PaginatedQuery{Limit: 50}.Parse(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	pq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	messages, err := app.store.Conversations.GetMessages(r.Context(), getConversationFromCtx(r).ID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var next *store.Cursor
+	if len(messages) > 0 {
+		last := messages[len(messages)-1]
+		next = nextCursor(len(messages), pq.Limit, last.CreatedAt, last.ID)
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, messages, next); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// SendMessage godoc
+//
+//	@Summary		Sends a message
+//	@Description	Sends a message to a conversation; message requests have to be accepted first
+//	@Tags			conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			conversationID	path		int					true	"Conversation ID"
+//	@Param			payload			body		SendMessagePayload	true	"Message payload"
+//	@Success		201				{object}	store.Message
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/messages [post]
+func (app *application) sendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SendMessagePayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	conversation := getConversationFromCtx(r)
+	user := getUserCtx(r)
+
+	message := &store.Message{
+		ConversationID: conversation.ID,
+		SenderID:       &user.ID,
+		Content:        payload.Content,
+	}
+
+	if err := app.store.Conversations.SendMessage(r.Context(), message); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrNotAccepted), errors.Is(err, store.ErrBlocked):
+			app.forbiddenResponse(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.publishMessage(conversation, message)
+
+	if err := app.jsonResponse(w, http.StatusCreated, message); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MarkMessagesRead godoc
+//
+//	@Summary		Marks a conversation as read
+//	@Description	Moves the read receipt of the user up to the given message
+//	@Tags			conversations
+//	@Accept			json
+//	@Produce		json
+//	@Param			conversationID	path		int						true	"Conversation ID"
+//	@Param			payload			body		MarkMessagesReadPayload	true	"Last read message"
+//	@Success		204				{string}	string					"Read receipt updated"
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/read [put]
+func (app *application) markMessagesReadHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MarkMessagesReadPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	conversation := getConversationFromCtx(r)
+	user := getUserCtx(r)
+
+	if err := app.store.Conversations.MarkRead(r.Context(), conversation.ID, user.ID, payload.MessageID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	receipt := map[string]int64{
+		"conversation_id": conversation.ID,
+		"user_id":         user.ID,
+		"message_id":      payload.MessageID,
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+
+		app.publish(ctx, realtime.EventMessageRead, receipt, conversation.MemberIDs()...)
+	}()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MuteConversation godoc
+//
+//	@Summary		Mutes a conversation
+//	@Description	Muted conversations do not count towards the unread conversations
+//	@Tags			conversations
+//	@Produce		json
+//	@Param			conversationID	path		int		true	"Conversation ID"
+//	@Success		204				{string}	string	"Conversation muted"
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/mute [put]
+func (app *application) muteConversationHandler(w http.ResponseWriter, r *http.Request) {
+	app.setConversationMuted(w, r, true)
+}
+
+// UnmuteConversation godoc
+//
+//	@Summary		Unmutes a conversation
+//	@Tags			conversations
+//	@Produce		json
+//	@Param			conversationID	path		int		true	"Conversation ID"
+//	@Success		204				{string}	string	"Conversation unmuted"
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/mute [delete]
+func (app *application) unmuteConversationHandler(w http.ResponseWriter, r *http.Request) {
+	app.setConversationMuted(w, r, false)
+}
+
+func (app *application) setConversationMuted(w http.ResponseWriter, r *http.Request, muted bool) {
+	conversation := getConversationFromCtx(r)
+
+	if err := app.store.Conversations.SetMuted(r.Context(), conversation.ID, getUserCtx(r).ID, muted); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptConversation godoc
+//
+//	@Summary		Accepts a message request
+//	@Tags			conversations
+//	@Produce		json
+//	@Param			conversationID	path		int		true	"Conversation ID"
+//	@Success		204				{string}	string	"Message request accepted"
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/accept [post]
+func (app *application) acceptConversationHandler(w http.ResponseWriter, r *http.Request) {
+	conversation := getConversationFromCtx(r)
+
+	if err := app.store.Conversations.Accept(r.Context(), conversation.ID, getUserCtx(r).ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LeaveConversation godoc
+//
+//	@Summary		Leaves a conversation
+//	@Description	Leaves a conversation, or declines a message request
+//	@Tags			conversations
+//	@Produce		json
+//	@Param			conversationID	path		int		true	"Conversation ID"
+//	@Success		204				{string}	string	"Conversation left"
+//	@Failure		401				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID} [delete]
+func (app *application) leaveConversationHandler(w http.ResponseWriter, r *http.Request) {
+	conversation := getConversationFromCtx(r)
+
+	if err := app.store.Conversations.Leave(r.Context(), conversation.ID, getUserCtx(r).ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishMessage streams a new message to the members of its conversation
+// who accepted it.
+func (app *application) publishMessage(conversation *store.Conversation, message *store.Message) {
+	members := conversation.MemberIDs()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+
+		app.publish(ctx, realtime.EventMessageCreated, message, members...)
+	}()
+}
+
+// conversationsContextMiddleware loads the conversation as seen by the
+// current user; conversations the user is not a member of do not exist.
+func (app *application) conversationsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		conversation, err := app.store.Conversations.GetByID(ctx, id, getUserCtx(r).ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, conversationCtx, conversation)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getConversationFromCtx(r *http.Request) *store.Conversation {
+	conversation, _ := r.Context().Value(conversationCtx).(*store.Conversation)
+	return conversation
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errVersionMismatch = errors.New("post has been modified, fetch it again and retry")
+
+// contentETag is the entity tag of a representation of a resource at the
+// given version. Representations embedding related data, like the comments
+// of a post, change without the version changing, so the tag also covers
+// the encoded body.
+func contentETag(version int, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + strconv.Itoa(version) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value lists etag or
+// is "*", using the weak comparison of If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// versionMatches reports whether the If-Match header value lists a tag of
+// the given version, either bare or as returned by contentETag, or is "*".
+// Weak tags never match under the strong comparison of If-Match.
+func versionMatches(header string, version int) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+
+		v, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+		if v == strconv.Itoa(version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch answers the request and returns false unless its If-Match
+// header carries a tag of the given version. Requests without If-Match are
+// rejected, so that clients cannot overwrite changes they have not seen.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, version int) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		app.preconditionRequiredResponse(w, r)
+		return false
+	}
+
+	if !versionMatches(header, version) {
+		app.preconditionFailedResponse(w, r, errVersionMismatch)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	etag := contentETag(3, []byte(`{"id":1}`))
+
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{etag, true},
+		{"W/" + etag, true},
+		{`"3"`, false},
+		{`"1", ` + etag, true},
+		{`*`, true},
+		{contentETag(3, []byte(`{"id":2}`)), false},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q, %q) = %v, want %v", tt.header, etag, got, tt.want)
+		}
+	}
+}
+
+func TestVersionMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"3"`, true},
+		{contentETag(3, []byte(`{}`)), true},
+		{`"2"`, false},
+		{`"1", "3"`, true},
+		{`*`, true},
+		{`W/"3"`, false},
+		{`3`, false},
+		{`"33-abc"`, false},
+	}
+
+	for _, tt := range tests {
+		if got := versionMatches(tt.header, 3); got != tt.want {
+			t.Errorf("versionMatches(%q, 3) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -12,10 +12,11 @@ import (
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
-//	@Param			since	query		string	false	"Since"
-//	@Param			until	query		string	false	"Until"
+//	@Param			since	query		string	false	"Since (RFC 3339, 2006-01-02 15:04:05 or 2006-01-02)"
+//	@Param			until	query		string	false	"Until (RFC 3339, 2006-01-02 15:04:05 or 2006-01-02)"
 //	@Param			limit	query		int		false	"Limit"
-//	@Param			offset	query		int		false	"Offset"
+//	@Param			offset	query		int		false	"Offset (ignored when cursor is set)"
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
 //	@Param			sort	query		string	false	"Sort"
 //	@Param			tags	query		string	false	"Tags"
 //	@Param			search	query		string	false	"Search"
@@ -33,8 +34,6 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	feedQuery.Sort = "desc"
 	feedQuery.Search = ""
 	feedQuery.Tags = []string{}
-	feedQuery.Since = "2024-07-16T08:28:28Z"
-	feedQuery.Until = "2024-11-26T08:28:28ZZ"
 
 	fq, err := feedQuery.Parse(r)
 
@@ -48,8 +47,15 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	fq.Cursor, err = app.readCursor(r)
+	if err != nil{
+		app.badRequest(w, r, err)
+		return
+	}
+
 	ctx := r.Context()
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(720), fq)
+	user := getUserCtx(r)
+	feed, err := app.getFeed(ctx, user.ID, fq)
 
 	if err != nil{
 		app.internalServerError(w, r, err)
@@ -57,7 +63,13 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 
-	if err := app.jsonResponse(w, http.StatusOK, feed); err !=nil{
+	var next *store.Cursor
+	if len(feed) > 0 {
+		last := feed[len(feed)-1].Cursor()
+		next = nextCursor(len(feed), fq.Limit, last.CreatedAt, last.ID)
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, feed, next); err !=nil{
 		app.internalServerError(w, r, err)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"go-project/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetFollowRequests godoc
+//
+//	@Summary		Fetches pending follow requests
+//	@Description	Fetches the users asking to follow the authenticated user, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	[]store.FollowRequest
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	pq, err := store.PaginatedQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	pq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	requests, err := app.store.Followers.GetRequests(r.Context(), getUserCtx(r).ID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var next *store.Cursor
+	if len(requests) > 0 {
+		last := requests[len(requests)-1]
+		next = nextCursor(len(requests), pq.Limit, last.CreatedAt, last.ID)
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, requests, next); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary		Approves a follow request
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"ID of the requesting user"
+//	@Success		204		{string}	string	"Follow request approved"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID} [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	followerID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserCtx(r)
+
+	if err := app.store.Followers.ApproveRequest(ctx, user.ID, followerID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateTimeline(ctx, followerID)
+
+	// the follower learns that the request was approved
+	go app.notify(store.Notification{
+		UserID:  followerID,
+		ActorID: user.ID,
+		Kind:    store.NotificationFollow,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DenyFollowRequest godoc
+//
+//	@Summary		Denies a follow request
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"ID of the requesting user"
+//	@Success		204		{string}	string	"Follow request denied"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID} [delete]
+func (app *application) denyFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	followerID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := app.store.Followers.DenyRequest(r.Context(), getUserCtx(r).ID, followerID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
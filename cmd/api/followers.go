@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"go-project/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type connectionsFunc func(ctx context.Context, userID, viewerID int64, pg store.PaginatedQuery) ([]store.Connection, error)
+
+// GetFollowers godoc
+//
+//	@Summary		Fetches the followers of a user
+//	@Description	Fetches the users following a user, most recent first. The list of a private account is only visible to the account and its followers.
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	[]store.Connection
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.connectionsResponse(w, r, app.store.Followers.GetFollowers)
+}
+
+// GetFollowing godoc
+//
+//	@Summary		Fetches the users a user follows
+//	@Description	Fetches the users followed by a user, most recent first. The list of a private account is only visible to the account and its followers.
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	[]store.Connection
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.connectionsResponse(w, r, app.store.Followers.GetFollowing)
+}
+
+func (app *application) connectionsResponse(w http.ResponseWriter, r *http.Request, list connectionsFunc) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	pq, err := store.PaginatedQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	pq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	viewer := getUserCtx(r)
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		app.notFoundError(w, r, store.ErrNotFound)
+		return
+	}
+
+	profile, err := app.store.Users.GetProfile(ctx, userID, viewer.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if profile.IsPrivate && profile.ID != viewer.ID && !profile.FollowedByMe {
+		app.notFoundError(w, r, store.ErrNotFound)
+		return
+	}
+
+	connections, err := list(ctx, userID, viewer.ID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var next *store.Cursor
+	if len(connections) > 0 {
+		last := connections[len(connections)-1]
+		next = nextCursor(len(connections), pq.Limit, last.FollowedAt, last.ID)
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, connections, next); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"go-project/internal/store"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// hashtagPattern matches #tag when the # does not continue a word, an HTML
+// entity or a URL fragment.
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}\p{M}_#&/])#([\p{L}\p{N}\p{M}_]+)`)
+
+// trendingWindows are the sliding windows trending tags are computed over.
+var trendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": time.Hour * 24,
+	"7d":  time.Hour * 24 * 7,
+}
+
+// trendingMinAuthors keeps a tag used by a single author from trending.
+const trendingMinAuthors = 2
+
+// parseHashtags returns the hashtags in content, without their #, in order
+// of first appearance.
+func parseHashtags(content string) []string {
+	var tags []string
+	for _, match := range hashtagPattern.FindAllStringSubmatch(content, -1) {
+		tags = append(tags, match[1])
+	}
+
+	return tags
+}
+
+// editedTags returns the tags of a post whose content changed from before
+// to after: the hashtags of before make way for those of after, while the
+// tags given apart from the content are kept.
+func editedTags(tags []string, before, after string) []string {
+	previous := make(map[string]bool)
+	for _, tag := range store.NormalizeTags(parseHashtags(before)) {
+		previous[tag] = true
+	}
+
+	var kept []string
+	for _, tag := range tags {
+		if !previous[tag] {
+			kept = append(kept, tag)
+		}
+	}
+
+	return store.NormalizeTags(append(kept, parseHashtags(after)...))
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		Fetches the posts with a hashtag
+//	@Description	Fetches the posts tagged with a hashtag, newest first. The tag is matched in its canonical form, so #Go and go are the same tag.
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Hashtag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	[]store.PostswithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := app.readTag(w, r)
+	if !ok {
+		return
+	}
+
+	pq, err := store.PaginatedQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	pq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	posts, err := app.store.Hashtags.GetPosts(r.Context(), tag, getUserCtx(r).ID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var next *store.Cursor
+	if len(posts) > 0 {
+		last := posts[len(posts)-1].Cursor()
+		next = nextCursor(len(posts), pq.Limit, last.CreatedAt, last.ID)
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, posts, next); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetTrendingTags godoc
+//
+//	@Summary		Fetches trending hashtags
+//	@Description	Fetches the hashtags used by the most authors within a sliding window, favouring tags whose use grew compared to the window before
+//	@Tags			tags
+//	@Produce		json
+//	@Param			window	query		string	false	"1h, 24h (default) or 7d"
+//	@Param			limit	query		int		false	"Limit, at most 50"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	window := trendingWindows["24h"]
+	if param := r.URL.Query().Get("window"); param != "" {
+		var ok bool
+		if window, ok = trendingWindows[param]; !ok {
+			app.badRequest(w, r, errors.New("window must be one of 1h, 24h or 7d"))
+			return
+		}
+	}
+
+	limit := 10
+	if param := r.URL.Query().Get("limit"); param != "" {
+		l, err := strconv.Atoi(param)
+		if err != nil || l < 1 || l > 50 {
+			app.badRequest(w, r, errors.New("limit must be between 1 and 50"))
+			return
+		}
+		limit = l
+	}
+
+	trending, err := app.store.Hashtags.Trending(r.Context(), window, trendingMinAuthors, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, trending); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetFollowedTags godoc
+//
+//	@Summary		Fetches the hashtags the authenticated user follows
+//	@Tags			tags
+//	@Produce		json
+//	@Success		200	{object}	[]store.Hashtag
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/following [get]
+func (app *application) getFollowedTagsHandler(w http.ResponseWriter, r *http.Request) {
+	tags, err := app.store.Hashtags.GetFollowed(r.Context(), getUserCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// FollowTag godoc
+//
+//	@Summary		Follows a hashtag
+//	@Description	Follows a hashtag so that the posts tagged with it appear in the feed
+//	@Tags			tags
+//	@Param			tag	path		string	true	"Hashtag"
+//	@Success		204	{string}	string	"Tag followed"
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error	"Tag already followed"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/follow [put]
+func (app *application) followTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := app.readTag(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.store.Hashtags.Follow(r.Context(), getUserCtx(r).ID, tag); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictErr(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnfollowTag godoc
+//
+//	@Summary		Unfollows a hashtag
+//	@Tags			tags
+//	@Param			tag	path		string	true	"Hashtag"
+//	@Success		204	{string}	string	"Tag unfollowed"
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error	"Tag not followed"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/follow [delete]
+func (app *application) unfollowTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := app.readTag(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.store.Hashtags.Unfollow(r.Context(), getUserCtx(r).ID, tag); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readTag reads the tag URL parameter in its canonical form. It writes the
+// error response itself and reports whether it succeeded.
+func (app *application) readTag(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tag, ok := store.NormalizeTag(chi.URLParam(r, "tag"))
+	if !ok {
+		app.badRequest(w, r, errors.New("invalid tag"))
+		return "", false
+	}
+
+	return tag, true
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHashtags(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"#go is fun", []string{"go"}},
+		{"learning #Go and #rust_lang, #café!", []string{"Go", "rust_lang", "café"}},
+		{"no#tag here", nil},
+		{"see https://example.com/page#section", nil},
+		{"it&#39;s an entity", nil},
+		{"##double", nil},
+		{"#a#b", []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseHashtags(tt.content); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseHashtags(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestEditedTags(t *testing.T) {
+	tests := []struct {
+		tags          []string
+		before, after string
+		want          []string
+	}{
+		{[]string{"go"}, "#go", "#rust", []string{"rust"}},
+		{[]string{"news", "go"}, "#go", "#Go and #rust", []string{"news", "go", "rust"}},
+		{[]string{"news", "go"}, "#go", "nothing", []string{"news"}},
+		{[]string{"news"}, "plain", "still plain", []string{"news"}},
+	}
+
+	for _, tt := range tests {
+		if got := editedTags(tt.tags, tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("editedTags(%v, %q, %q) = %v, want %v", tt.tags, tt.before, tt.after, got, tt.want)
+		}
+	}
+}
@@ -11,6 +11,9 @@ var Validate *validator.Validate
 
 func init (){
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+	Validate.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	})
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"expvar"
 	"go-project/internal/auth"
+	"go-project/internal/blob"
+	"go-project/internal/cursor"
 	"go-project/internal/db"
 	"go-project/internal/env"
 	"go-project/internal/mailer"
 	"go-project/internal/ratelimiter"
+	"go-project/internal/realtime"
 	"go-project/internal/store"
 	"go-project/internal/store/cache"
 	"runtime"
@@ -42,6 +46,7 @@ func main() {
 		addr: env.GetString("ADDR", ":3000"),
 		apiURL: env.GetString("EXTERNAL_URL", "localhost:8080"),
 		frontendURL: env.GetString("FRONTEND_URL", "http://localhost:3000"),
+		allowedOrigin: env.GetString("CORS_ALLOWED_ORIGIN", "http://localhost:3000"),
 		db: dbConfig{
 			addr: env.GetString("DB_ADDR", ""),
 			maxOpenConns: env.GetInt("DB_MAX_OPEN_CONNS", 30),
@@ -51,6 +56,7 @@ func main() {
 		env: env.GetString("ENV", "development"),
 		mail: mailConfig{
 			mailExp: time.Hour * 24 * 2, //2 days
+			passwordResetExp: time.Hour,
 			sendGrid: sendGrid{
 				apikey: env.GetString("SENDGRID_API_KEY", ""),
 			},
@@ -63,7 +69,8 @@ func main() {
 			},
 			token: tokenConfig{
 				secret: env.GetString("AUTH_TOKEN_SECRETS", ""),
-				exp: time.Hour * 24 * 2,
+				exp: time.Minute * 15,
+				refreshExp: time.Hour * 24 * 30, //30 days
 				iss: "ConnectApp Social",
 			},
 		},
@@ -79,6 +86,34 @@ func main() {
 			TimeFrame: time.Second * 5,
 			Enabled: env.GetBool("RATELIMITER_REQUEST", true),
 		},
+		timeline: timelineConfig{
+			popularThreshold: env.GetInt("TIMELINE_POPULAR_FOLLOWERS", 10000),
+		},
+		upload: uploadConfig{
+			dir: env.GetString("UPLOAD_DIR", "./uploads"),
+			baseURL: env.GetString("UPLOAD_BASE_URL", "http://localhost:8080/v1/media"),
+			maxSize: int64(env.GetInt("UPLOAD_MAX_BYTES", 10<<20)), //10 MB
+			maxPixels: env.GetInt("UPLOAD_MAX_PIXELS", 40_000_000),
+		},
+		profile: profileConfig{
+			usernameCooldown: time.Hour * 24 * time.Duration(env.GetInt("USERNAME_COOLDOWN_DAYS", 30)),
+		},
+		scheduler: schedulerConfig{
+			interval: time.Second * time.Duration(env.GetInt("SCHEDULER_INTERVAL_SECONDS", 15)),
+			batchSize: env.GetInt("SCHEDULER_BATCH_SIZE", 100),
+		},
+		retention: retentionConfig{
+			restoreWindow: time.Hour * 24 * time.Duration(env.GetInt("RESTORE_WINDOW_DAYS", 7)),
+			period: time.Hour * 24 * time.Duration(env.GetInt("RETENTION_DAYS", 30)),
+			unattachedMedia: time.Hour * time.Duration(env.GetInt("UNATTACHED_MEDIA_HOURS", 24)),
+			purgeInterval: time.Hour,
+		},
+		stream: streamConfig{
+			backlog: env.GetInt("STREAM_BACKLOG", 1000),
+		},
+		pagination: paginationConfig{
+			cursorSecret: env.GetString("CURSOR_SECRET", env.GetString("AUTH_TOKEN_SECRETS", "")),
+		},
 
 	}
 	
@@ -108,6 +143,23 @@ func main() {
 
 	cacheStorage := cache.NewRedisStorage(rdb)
 
+	hub := realtime.NewHub(cfg.stream.backlog)
+	var events realtime.Broker = realtime.NewLocalBroker(hub)
+	if cfg.redis.enabled {
+		broker := realtime.NewRedisBroker(rdb, hub)
+		go func() {
+			if err := broker.Run(context.Background()); err != nil {
+				logger.Errorw("event broker stopped", "error", err)
+			}
+		}()
+		events = broker
+	}
+
+	blobs, err := blob.NewLocalStore(cfg.upload.dir, cfg.upload.baseURL)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	rateLimiter := ratelimiter.NewfixedWindowRateLimiter(
 		cfg.ratelimiter.RequestPerTimeFrame,
 		cfg.ratelimiter.TimeFrame,
@@ -123,6 +175,9 @@ func main() {
 		authenticator: JWTAuth, 
 		cacheStorage: cacheStorage,
 		ratelimiter: rateLimiter,
+		cursor: cursor.NewSigner(cfg.pagination.cursorSecret),
+		events: events,
+		blobs: blobs,
 	}
 
 	expvar.NewString("version").Set(version)	
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-project/internal/blob"
+	"go-project/internal/media"
+	"go-project/internal/store"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// UploadMedia godoc
+//
+//	@Summary		Uploads an image
+//	@Description	Uploads a JPEG, PNG, GIF or WebP image in the multipart field "file" to attach to a post. The image is re-encoded without its metadata and a thumbnail is generated.
+//	@Tags			media
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			file	formData	file	true	"Image"
+//	@Success		201		{object}	store.Media
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		413		{object}	error
+//	@Failure		415		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media [post]
+func (app *application) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	img, ok := app.readImageUpload(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserCtx(r)
+	name := uuid.New().String()
+
+	m := &store.Media{
+		UserID:       user.ID,
+		Key:          fmt.Sprintf("posts/%d/%s.%s", user.ID, name, img.Ext),
+		ThumbnailKey: fmt.Sprintf("posts/%d/%s-thumb.%s", user.ID, name, img.Ext),
+		ContentType:  img.ContentType,
+		Width:        img.Width,
+		Height:       img.Height,
+		Size:         int64(len(img.Data)),
+	}
+	m.URL = app.blobs.URL(m.Key)
+	m.ThumbnailURL = app.blobs.URL(m.ThumbnailKey)
+
+	if err := app.putBlob(r, m.Key, img.Data, img.ContentType); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.putBlob(r, m.ThumbnailKey, img.Thumbnail, img.ContentType); err != nil {
+		app.deleteBlobs(r.Context(), m.Key)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Media.Create(ctx, m); err != nil {
+		app.deleteBlobs(r.Context(), m.Key, m.ThumbnailKey)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, m); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateAvatar godoc
+//
+//	@Summary		Updates the avatar of the authenticated user
+//	@Description	Uploads a JPEG, PNG, GIF or WebP image in the multipart field "file" as avatar. The image is scaled down and re-encoded without its metadata.
+//	@Tags			users
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			file	formData	file	true	"Image"
+//	@Success		200		{object}	store.Users
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		413		{object}	error
+//	@Failure		415		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/avatar [put]
+func (app *application) updateAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	img, ok := app.readImageUpload(w, r)
+	if !ok {
+		return
+	}
+
+	user := *getUserCtx(r)
+	key := fmt.Sprintf("avatars/%d/%s.%s", user.ID, uuid.New().String(), img.Ext)
+
+	if err := app.putBlob(r, key, img.Thumbnail, img.ContentType); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user.AvatarURL = app.blobs.URL(key)
+	if !app.setAvatar(w, r, key, user.AvatarURL) {
+		app.deleteBlobs(r.Context(), key)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteAvatar godoc
+//
+//	@Summary		Removes the avatar of the authenticated user
+//	@Tags			users
+//	@Success		204	{string}	string	"Avatar removed"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/avatar [delete]
+func (app *application) deleteAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.setAvatar(w, r, "", "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMedia godoc
+//
+//	@Summary		Fetches an uploaded file
+//	@Description	Serves files of the local blob store; the URLs are returned by the upload endpoints
+//	@Tags			media
+//	@Produce		octet-stream
+//	@Param			key	path		string	true	"Blob key"
+//	@Success		200	{file}		file
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/media/{key} [get]
+func (app *application) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+
+	content, err := app.blobs.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer content.Close()
+
+	// keys are never reused, so the content behind a key never changes
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if _, err := io.Copy(w, content); err != nil {
+		app.logger.Warnw("error serving media", "key", key, "error", err)
+	}
+}
+
+// readImageUpload reads and processes the image in the "file" field of a
+// multipart request, within the upload size limit rather than the one of
+// readJSON. It writes the error response itself and reports whether it
+// succeeded.
+func (app *application) readImageUpload(w http.ResponseWriter, r *http.Request) (*media.Image, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.upload.maxSize)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			app.payloadTooLargeResponse(w, r, fmt.Errorf("upload exceeds %d bytes", tooLarge.Limit))
+		default:
+			app.badRequest(w, r, err)
+		}
+		return nil, false
+	}
+	defer file.Close()
+	defer r.MultipartForm.RemoveAll()
+
+	img, err := media.Process(file, app.config.upload.maxPixels)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			app.unsupportedMediaTypeResponse(w, r, err)
+		case errors.Is(err, media.ErrTooManyPixels):
+			app.badRequest(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return img, true
+}
+
+// setAvatar stores the new avatar of the authenticated user and removes the
+// previous one. It writes the error response itself and reports whether it
+// succeeded.
+func (app *application) setAvatar(w http.ResponseWriter, r *http.Request, key, url string) bool {
+	ctx := r.Context()
+	user := getUserCtx(r)
+
+	previous, err := app.store.Users.SetAvatar(ctx, user.ID, key, url)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return false
+	}
+
+	if previous != "" {
+		app.deleteBlobs(r.Context(), previous)
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	return true
+}
+
+func (app *application) putBlob(r *http.Request, key string, data []byte, contentType string) error {
+	return app.blobs.Put(r.Context(), key, bytes.NewReader(data), contentType)
+}
+
+// deleteBlobs removes blobs that are no longer referenced. Failures only
+// leave orphaned files behind, so they are logged rather than returned.
+func (app *application) deleteBlobs(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := app.blobs.Delete(ctx, key); err != nil {
+			app.logger.Warnw("error deleting blob", "key", key, "error", err)
+		}
+	}
+}
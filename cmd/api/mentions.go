@@ -0,0 +1,43 @@
+package main
+
+import (
+	"go-project/internal/store"
+	"regexp"
+	"unicode/utf8"
+)
+
+// mentionPattern matches @username when the @ does not continue a word,
+// so that email addresses are not taken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w{1,100})`)
+
+// usernamePattern is what usernames are made of, which keeps every user
+// mentionable.
+var usernamePattern = regexp.MustCompile(`^\w{1,100}$`)
+
+// extractMentions returns every @username in content, in order, with its
+// offsets in Unicode code points. The mentions are not linked to users yet.
+func extractMentions(content string) []store.Mention {
+	mentions := []store.Mention{}
+
+	var (
+		offset int // byte offset up to which runes were counted
+		runes  int // runes in content[:offset]
+	)
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		// the @ is the byte just before the username
+		start, end := match[2]-1, match[3]
+
+		runes += utf8.RuneCountInString(content[offset:start])
+		startRunes := runes
+		runes += utf8.RuneCountInString(content[start:end])
+		offset = end
+
+		mentions = append(mentions, store.Mention{
+			Username: content[match[2]:match[3]],
+			Start:    startRunes,
+			End:      runes,
+		})
+	}
+
+	return mentions
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"go-project/internal/store"
+	"slices"
+	"testing"
+)
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []store.Mention
+	}{
+		{"hello @alice and @bob", []store.Mention{
+			{Username: "alice", Start: 6, End: 12},
+			{Username: "bob", Start: 17, End: 21},
+		}},
+		{"@alice first, @alice again", []store.Mention{
+			{Username: "alice", Start: 0, End: 6},
+			{Username: "alice", Start: 14, End: 20},
+		}},
+		{"héllo wörld @carol", []store.Mention{
+			{Username: "carol", Start: 12, End: 18},
+		}},
+		{"mail me at bob@example.com", []store.Mention{}},
+		{"(@carol_1)", []store.Mention{{Username: "carol_1", Start: 1, End: 9}}},
+		{"no mentions @ all", []store.Mention{}},
+	}
+
+	for _, tt := range tests {
+		if got := extractMentions(tt.content); !slices.Equal(got, tt.want) {
+			t.Errorf("extractMentions(%q) = %v, expected %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestUsernameValidation(t *testing.T) {
+	tests := []struct {
+		username string
+		valid    bool
+	}{
+		{"alice", true},
+		{"carol_1", true},
+		{"bob.smith", false},
+		{"bob-smith", false},
+		{"jöhn", false},
+		{"a b", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		err := Validate.Var(tt.username, "username")
+		if valid := err == nil; valid != tt.valid {
+			t.Errorf("username %q valid = %v, expected %v", tt.username, valid, tt.valid)
+		}
+		// every valid username can be mentioned
+		if tt.valid && len(extractMentions("@"+tt.username)) != 1 {
+			t.Errorf("username %q cannot be mentioned", tt.username)
+		}
+	}
+}
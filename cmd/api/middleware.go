@@ -9,10 +9,15 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+type claimsKey string
+
+const claimsCtx claimsKey = "claims"
+
 func (app *application) BasicMiddlewareAuth() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -55,6 +60,7 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		if authHeader == "" {
 			app.unAuthorizedError(w, r, fmt.Errorf("not Authorized, missing credentials"))
+			return
 		}
 
 		//parse it
@@ -82,13 +88,32 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 		}
 
 		ctx := r.Context()
+
+		jti, _ := claims["jti"].(string)
+		if jti == "" {
+			app.unAuthorizedError(w, r, fmt.Errorf("not Authorized, token has no id"))
+			return
+		}
+
 		user, err := app.getUser(ctx, userId)
 		if err != nil {
 			app.unAuthorizedError(w, r, err)
 			return
 		}
 
+		revoked, err := app.isTokenRevoked(ctx, claims, user)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if revoked {
+			app.unAuthorizedError(w, r, fmt.Errorf("not Authorized, token has been revoked"))
+			return
+		}
+
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, claimsCtx, claims)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -97,10 +122,15 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 func (app *application) RoleBasedAuthMiddleware(role string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := getUserCtx(r)
-		post := getPostFromCtx(r)
 
-		fmt.Println(post)
-		if post.UserID == user.ID {
+		// the owner of the resource (the comment when one is in the
+		// context, the post otherwise) is always permitted
+		ownerID := getPostFromCtx(r).UserID
+		if comment := getCommentFromCtx(r); comment != nil {
+			ownerID = int64(comment.UserID)
+		}
+
+		if ownerID == user.ID {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -113,7 +143,6 @@ func (app *application) RoleBasedAuthMiddleware(role string, next http.HandlerFu
 			return
 		}
 
-		fmt.Println(Ispermitted)
 		if !Ispermitted {
 			app.forbiddenResponse(w, r)
 			return
@@ -147,7 +176,7 @@ func(app *application) getUser(ctx context.Context, userId int64) (*store.Users,
 	}
 	
 	if user == nil {
-		user, err := app.store.Users.GetUser(ctx, userId)
+		user, err = app.store.Users.GetUser(ctx, userId)
 		if err != nil {
 			return nil, err
 		}
@@ -161,6 +190,55 @@ func(app *application) getUser(ctx context.Context, userId int64) (*store.Users,
 }
 
 
+// invalidateUser drops the cached copy of a user after it changed.
+func (app *application) invalidateUser(ctx context.Context, userID int64) {
+	if !app.config.redis.enabled {
+		return
+	}
+
+	if err := app.cacheStorage.Users.Delete(ctx, userID); err != nil {
+		app.logger.Errorw("error invalidating cached user", "user", userID, "error", err)
+	}
+}
+
+func (app *application) isTokenDenied(ctx context.Context, jti string) (bool, error) {
+	if !app.config.redis.enabled {
+		return app.store.Tokens.IsDenied(ctx, jti)
+	}
+
+	return app.cacheStorage.Tokens.IsDenied(ctx, jti)
+}
+
+// isTokenRevoked reports whether the access token with the given claims
+// was revoked, by logging out or by resetting the password of user.
+func (app *application) isTokenRevoked(ctx context.Context, claims jwt.MapClaims, user *store.Users) (bool, error) {
+	jti, _ := claims["jti"].(string)
+
+	denied, err := app.isTokenDenied(ctx, jti)
+	if err != nil || denied {
+		return denied, err
+	}
+
+	// resetting the password revokes the tokens issued before, up to the
+	// end of the second of the reset
+	iat, _ := claims["iat"].(float64)
+
+	return user.TokensValidAfter != nil && int64(iat) < user.TokensValidAfter.Unix(), nil
+}
+
+func (app *application) denyToken(ctx context.Context, jti string, exp time.Time) error {
+	if !app.config.redis.enabled {
+		return app.store.Tokens.Deny(ctx, jti, exp)
+	}
+
+	return app.cacheStorage.Tokens.Deny(ctx, jti, exp)
+}
+
+func getClaimsCtx(r *http.Request) jwt.MapClaims {
+	claims, _ := r.Context().Value(claimsCtx).(jwt.MapClaims)
+	return claims
+}
+
 func (app *application) RateLimiterMiddleware (next http.Handler)http.Handler{
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.ratelimiter.Enabled{
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go-project/internal/realtime"
+	"go-project/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// notificationTimeout bounds the background work of producing a
+// notification after a request has been answered.
+const notificationTimeout = time.Second * 5
+
+type NotificationsResponse struct {
+	UnreadCount   int                       `json:"unread_count"`
+	Notifications []store.NotificationGroup `json:"notifications"`
+}
+
+type MarkNotificationsReadPayload struct {
+	// IDs of the notifications to mark as read, all of them when empty.
+	IDs []int64 `json:"ids" validate:"max=500,dive,gte=1"`
+}
+
+// GetNotifications godoc
+//
+//	@Summary		Fetches the user's notifications
+//	@Description	Fetches the notifications of the user, most recent first, with similar notifications grouped together
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			unread	query		bool	false	"Only unread notifications"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	NotificationsResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	pq, err := store.PaginatedQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	pq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	var unreadOnly bool
+	if param := r.URL.Query().Get("unread"); param != "" {
+		unreadOnly, err = strconv.ParseBool(param)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	user := getUserCtx(r)
+
+	groups, err := app.store.Notifications.List(ctx, user.ID, unreadOnly, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	unread, err := app.store.Notifications.CountUnread(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var next *store.Cursor
+	if len(groups) > 0 {
+		last := groups[len(groups)-1]
+		next = nextCursor(len(groups), pq.Limit, last.CreatedAt, last.LatestID)
+	}
+
+	response := NotificationsResponse{UnreadCount: unread, Notifications: groups}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, response, next); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUnreadNotificationsCount godoc
+//
+//	@Summary		Counts unread notifications
+//	@Description	Returns the number of unread notifications of the user
+//	@Tags			notifications
+//	@Produce		json
+//	@Success		200	{object}	map[string]int
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/unread-count [get]
+func (app *application) getUnreadNotificationsCountHandler(w http.ResponseWriter, r *http.Request) {
+	count, err := app.store.Notifications.CountUnread(r.Context(), getUserCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, map[string]int{"unread_count": count}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MarkNotificationRead godoc
+//
+//	@Summary		Marks a notification as read
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			notificationID	path		int		true	"Notification ID"
+//	@Success		204				{string}	string	"Notification marked as read"
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/{notificationID}/read [put]
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "notificationID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := app.store.Notifications.MarkRead(r.Context(), getUserCtx(r).ID, []int64{id}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkNotificationsRead godoc
+//
+//	@Summary		Marks notifications as read
+//	@Description	Marks the given notifications as read, or all of them when no ids are given
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MarkNotificationsReadPayload	false	"Notifications to mark"
+//	@Success		204		{string}	string							"Notifications marked as read"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/read [post]
+func (app *application) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MarkNotificationsReadPayload
+
+	if r.ContentLength != 0 {
+		if err := readJSON(w, r, &payload); err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		if err := Validate.Struct(payload); err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+	}
+
+	if err := app.store.Notifications.MarkRead(r.Context(), getUserCtx(r).ID, payload.IDs); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetNotificationPreferences godoc
+//
+//	@Summary		Fetches notification preferences
+//	@Description	Returns whether each kind of notification is enabled for the user
+//	@Tags			notifications
+//	@Produce		json
+//	@Success		200	{object}	map[string]bool
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/preferences [get]
+func (app *application) getNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	prefs, err := app.store.Notifications.GetPreferences(r.Context(), getUserCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, prefs); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateNotificationPreferences godoc
+//
+//	@Summary		Updates notification preferences
+//	@Description	Enables or disables kinds of notifications; kinds left out are unchanged
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		map[string]bool	true	"Enabled state by kind"
+//	@Success		200		{object}	map[string]bool
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/preferences [patch]
+func (app *application) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	var payload map[string]bool
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	for kind := range payload {
+		if !store.IsNotificationKind(kind) {
+			app.badRequest(w, r, fmt.Errorf("unknown notification kind %q, expected one of %s", kind, strings.Join(store.NotificationKinds, ", ")))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	user := getUserCtx(r)
+
+	if err := app.store.Notifications.UpdatePreferences(ctx, user.ID, payload); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	prefs, err := app.store.Notifications.GetPreferences(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, prefs); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// notify stores a notification. It is meant to run in its own goroutine,
+// so a failure only gets logged.
+func (app *application) notify(n store.Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+
+	if err := app.store.Notifications.Create(ctx, &n); err != nil {
+		app.logger.Errorw("error creating notification", "kind", n.Kind, "user", n.UserID, "error", err)
+		return
+	}
+
+	if n.ID != 0 {
+		app.publish(ctx, realtime.EventNotification, n, n.UserID)
+	}
+}
+
+// notifyMentions notifies the users linked by mentions, except those that
+// were already mentioned in previous, the mentions of the content before an
+// edit, and those that may not see the post. It is meant to run in its own
+// goroutine.
+func (app *application) notifyMentions(n store.Notification, mentions, previous []store.Mention) {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+
+	notified := make(map[int64]bool, len(previous))
+	for _, m := range previous {
+		notified[m.UserID] = true
+	}
+
+	n.Kind = store.NotificationMention
+	for _, m := range mentions {
+		if notified[m.UserID] {
+			continue
+		}
+		notified[m.UserID] = true
+
+		visible, err := app.store.Posts.IsVisible(ctx, *n.PostID, m.UserID)
+		if err != nil {
+			app.logger.Errorw("error checking post visibility", "post", *n.PostID, "user", m.UserID, "error", err)
+			continue
+		}
+
+		if visible {
+			n.UserID = m.UserID
+			app.notify(n)
+		}
+	}
+}
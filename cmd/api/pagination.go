@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"go-project/internal/store"
+	"net/http"
+)
+
+// readCursor decodes the optional cursor query parameter.
+func (app *application) readCursor(r *http.Request) (*store.Cursor, error) {
+	token := r.URL.Query().Get("cursor")
+	if token == "" {
+		return nil, nil
+	}
+
+	return app.cursor.Decode(token)
+}
+
+// paginatedResponse writes a page of a cursor paginated list. When there
+// is a next page its cursor is returned in the envelope and advertised in
+// the Link header.
+func (app *application) paginatedResponse(w http.ResponseWriter, r *http.Request, status int, data any, next *store.Cursor) error {
+	type envelope struct {
+		Data       any    `json:"data"`
+		NextCursor string `json:"next_cursor,omitempty"`
+	}
+
+	env := &envelope{Data: data}
+
+	if next != nil {
+		token, err := app.cursor.Encode(*next)
+		if err != nil {
+			return err
+		}
+
+		query := r.URL.Query()
+		query.Set("cursor", token)
+		query.Del("offset")
+
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, query.Encode()))
+		env.NextCursor = token
+	}
+
+	return writeJSON(w, status, env)
+}
+
+// nextCursor returns the position after the last item of a full page, or
+// nil when the page is the last one.
+func nextCursor(count, limit int, createdAt string, id int64) *store.Cursor {
+	if count == 0 || count < limit {
+		return nil
+	}
+
+	return &store.Cursor{CreatedAt: createdAt, ID: id}
+}
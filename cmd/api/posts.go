@@ -2,32 +2,45 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"go-project/internal/store"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 type postKey string
 var postCtx postKey
 
+var errRepostNotEditable = errors.New("reposts cannot be edited")
+
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title     string   `json:"title" validate:"required,max=100"`
+	Content   string   `json:"content" validate:"required,max=1000"`
+	Tags      []string `json:"tags" validate:"max=10"`
+	QuoteOfID *int64   `json:"quote_of_id" validate:"omitempty,gte=1"`
+	MediaIDs  []int64  `json:"media_ids" validate:"omitempty,max=4,unique,dive,gte=1"`
+	Visibility string  `json:"visibility" validate:"omitempty,oneof=public followers mentioned private"`
+	Status    string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 
 type UpdatePostPayload struct {
 	Title   *string   `json:"title" validate:"omitempty,max=100"`
 	Content *string   `json:"content" validate:"omitempty,max=100"`
+	Visibility *string `json:"visibility" validate:"omitempty,oneof=public followers mentioned private"`
+	Status    *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 // CreatePost godoc
 //
 //	@Summary		Creates a post
-//	@Description	Creates a post
+//	@Description	Creates a post tagged with the given tags and the #hashtags in its content, optionally with up to 4 images uploaded through /media attached in the order of media_ids. The visibility is public (default), followers, mentioned or private. Posts are published right away unless they are saved as a draft or scheduled with publish_at.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -55,18 +68,75 @@ func (app *application) createPosts(w http.ResponseWriter, r *http.Request) {
 
 	user := getUserCtx(r)
 
+	status, err := postStatus(payload.Status, payload.PublishAt, time.Now())
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	for _, tag := range payload.Tags {
+		if _, ok := store.NormalizeTag(tag); !ok {
+			app.badRequest(w, r, fmt.Errorf("invalid tag %q", tag))
+			return
+		}
+	}
+
+	if payload.QuoteOfID != nil {
+		quoted, err := app.store.Posts.GetbyID(ctx, *payload.QuoteOfID)
+		if err == nil {
+			// posts the user may not see do not exist for them
+			var visible bool
+			if visible, err = app.store.Posts.IsVisible(ctx, quoted.ID, user.ID); err == nil && (!visible || quoted.Status != store.StatusPublished) {
+				err = store.ErrNotFound
+			}
+		}
+
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequest(w, r, errors.New("quoted post does not exist"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		// quoting a repost quotes the reposted post
+		if quoted.RepostOfID != nil {
+			payload.QuoteOfID = quoted.RepostOfID
+		}
+	}
+
 	post := &store.Posts{
 		Title:   payload.Title,
 		Content: payload.Content,
 		UserID: 	user.ID,
-		Tags:    payload.Tags,
+		Tags:    store.NormalizeTags(append(payload.Tags, parseHashtags(payload.Content)...)),
+		QuoteOfID: payload.QuoteOfID,
+		Mentions: extractMentions(payload.Content),
+		Visibility: payload.Visibility,
+		Status: status,
+		PublishAt: payload.PublishAt,
+	}
+
+	for _, id := range payload.MediaIDs {
+		post.Media = append(post.Media, store.Media{ID: id})
 	}
 
 	if err := app.store.Posts.Create(ctx, post); err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrInvalidMedia):
+			app.badRequest(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	if post.Status == store.StatusPublished {
+		app.announcePost(post)
+	}
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -77,39 +147,100 @@ func (app *application) createPosts(w http.ResponseWriter, r *http.Request) {
 // GetPost godoc
 //
 //	@Summary		Fetches a post
-//	@Description	Fetches a post by ID
+//	@Description	Fetches a post by ID. The ETag of the response identifies the version of the post and the rest of the response, comments and counters included; sending it back in If-None-Match returns 304 while the response is unchanged, and in If-Match it updates this version of the post.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Post ID"
-//	@Success		200	{object}	store.Posts
-//	@Failure		404	{object}	error
+//	@Param			id				path		int		true	"Post ID"
+//	@Param			If-None-Match	header		string	false	"ETag of a previously fetched version"
+//	@Success		200				{object}	store.PostswithMetadata
+//	@Header			200				{string}	ETag	"Version of the post and its response"
+//	@Success		304				{object}	string
+//	@Failure		404				{object}	error
 //	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [get]
 func (app *application) getPosts(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
-	
-
-	comments, err := app.store.Comments.GetbyPostID(r.Context(), post.ID)
+	user := getUserCtx(r)
 
+	response, body, err := app.postResponse(r.Context(), post.ID, user.ID)
 	if err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
-	post.Comment = comments
+	etag := contentETag(response.Version, body)
+	w.Header().Set("ETag", etag)
 
-	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
 
+// postResponse loads a post the way getPosts serves it to the user, comments
+// included, together with its encoded body.
+func (app *application) postResponse(ctx context.Context, postID, userID int64) (*store.PostswithMetadata, []byte, error) {
+	// a repost is shown as the reposted post attributed to the reposter
+	posts, err := app.store.Posts.GetFeedByIDs(ctx, userID, []int64{postID})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(posts) == 0 {
+		return nil, nil, store.ErrNotFound
+	}
+
+	response := posts[0]
+
+	comments, err := app.store.Comments.GetbyPostID(ctx, response.ID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response.Comment = comments
+	response.CommentCount = countComments(comments)
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &response, body, nil
+}
+
+// setPostETag sets the ETag that getPosts would serve for the post after a
+// write, so that it can be sent back in If-None-Match as well as If-Match.
+// No ETag is set when the user cannot fetch the post, like a moderator
+// editing someone else's draft.
+func (app *application) setPostETag(w http.ResponseWriter, r *http.Request, postID int64) error {
+	response, body, err := app.postResponse(r.Context(), postID, getUserCtx(r).ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", contentETag(response.Version, body))
+	return nil
+}
+
 // DeletePost godoc
 //
 //	@Summary		Deletes a post
-//	@Description	Delete a post by ID
+//	@Description	Deletes a post by ID. The post can be restored by its author or a moderator within the grace window and is purged for good once the retention period is over. Deleting a repost undoes it.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -129,7 +260,17 @@ func (app *application) deletePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	if err := app.store.Posts.DeletebyID(ctx, id); err != nil {
+	post := getPostFromCtx(r)
+
+	// a repost has nothing worth restoring, and a deleted one would keep
+	// its author from reposting the post again
+	if post.RepostOfID != nil {
+		_, err = app.store.Posts.Unrepost(ctx, post.UserID, *post.RepostOfID)
+	} else {
+		err = app.store.Posts.DeletebyID(ctx, id)
+	}
+
+	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundError(w, r, err)
@@ -139,29 +280,45 @@ func (app *application) deletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	go app.removeFromTimelines(post)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // UpdatePost godoc
 //
 //	@Summary		Updates a post
-//	@Description	Updates a post by ID
+//	@Description	Updates a post by ID. Drafts and scheduled posts can be rescheduled, turned into drafts or published by setting status and publish_at; published posts stay published. Editing the content replaces the #hashtags taken from it. Reposts cannot be edited. If-Match has to carry the ETag of the version the changes were made to.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int					true	"Post ID"
-//	@Param			payload	body		UpdatePostPayload	true	"Post payload"
-//	@Success		200		{object}	store.Posts
-//	@Failure		400		{object}	error
-//	@Failure		401		{object}	error
-//	@Failure		404		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			id			path		int					true	"Post ID"
+//	@Param			If-Match	header		string				true	"ETag of the version being updated"
+//	@Param			payload		body		UpdatePostPayload	true	"Post payload"
+//	@Success		200			{object}	store.Posts
+//	@Header			200			{string}	ETag	"ETag of the updated post, as served when fetching it"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [patch]
 func (app *application) updatePost(w http.ResponseWriter, r *http.Request) {
 
 	post := getPostFromCtx(r)
 
+	if post.RepostOfID != nil {
+		app.conflictErr(w, r, errRepostNotEditable)
+		return
+	}
+
+	if !app.checkIfMatch(w, r, post.Version) {
+		return
+	}
+
 	var payload UpdatePostPayload
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequest(w, r, err)
@@ -173,17 +330,65 @@ func (app *application) updatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	previous := post.Mentions
+	wasPublished := post.Status == store.StatusPublished
+
+	if payload.Status != nil || payload.PublishAt != nil {
+		var requested string
+		if payload.Status != nil {
+			requested = *payload.Status
+		}
+
+		status, err := postStatus(requested, payload.PublishAt, time.Now())
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		if wasPublished && status != store.StatusPublished {
+			app.badRequest(w, r, errAlreadyPublished)
+			return
+		}
+
+		post.Status = status
+		post.PublishAt = payload.PublishAt
+	}
+
 	if payload.Content != nil {
+		post.Tags = editedTags(post.Tags, post.Content, *payload.Content)
 		post.Content = *payload.Content
+		post.Mentions = extractMentions(post.Content)
 	}
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
 
 	ctx := r.Context()
 
 	if err := app.store.Posts.UpdatebyID(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			// the post exists, so it was updated since it was read
+			app.preconditionFailedResponse(w, r, errVersionMismatch)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	switch {
+	case !wasPublished && post.Status == store.StatusPublished:
+		app.announcePost(post)
+	case wasPublished:
+		go app.notifyMentions(store.Notification{ActorID: post.UserID, PostID: &post.ID}, post.Mentions, previous)
+	}
+
+	if err := app.setPostETag(w, r, post.ID); err != nil {
 		app.internalServerError(w, r, err)
+		return
 	}
 
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
@@ -191,6 +396,99 @@ func (app *application) updatePost(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// RepostPost godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Shares a post with the followers of the authenticated user. Reposting a repost reposts the original post. Only published public posts can be reposted, and posts of private accounts cannot be reposted by others.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		201		{object}	store.Posts
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [post]
+func (app *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserCtx(r)
+	post := getPostFromCtx(r)
+	ctx := r.Context()
+
+	if post.Visibility != store.VisibilityPublic || post.Status != store.StatusPublished {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if post.UserID != user.ID {
+		author, err := app.getUser(ctx, post.UserID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if author.IsPrivate {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	repost, err := app.store.Posts.Repost(ctx, user.ID, post.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictErr(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	go app.fanOutPost(repost)
+	go app.notify(store.Notification{
+		UserID:  post.UserID,
+		ActorID: user.ID,
+		Kind:    store.NotificationRepost,
+		PostID:  &post.ID,
+	})
+
+	if err := app.jsonResponse(w, http.StatusCreated, repost); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UndoRepost godoc
+//
+//	@Summary		Undoes a repost
+//	@Description	Removes the authenticated user's repost of a post
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		204		{object}	string
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [delete]
+func (app *application) undoRepostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserCtx(r)
+
+	id, err := app.store.Posts.Unrepost(r.Context(), user.ID, getPostFromCtx(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	go app.removeFromTimelines(&store.Posts{ID: id, UserID: user.ID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "postID")
@@ -214,11 +512,63 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			}
 			return
 		}
+
+		// posts a viewer may not see do not exist for them, so that their
+		// existence is not leaked
+		visible, err := app.store.Posts.IsVisible(ctx, post.ID, getUserCtx(r).ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !visible {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// countComments counts the comments of a comment tree, replies included.
+func countComments(comments []store.Comment) int {
+	count := len(comments)
+	for _, c := range comments {
+		count += countComments(c.Replies)
+	}
+	return count
+}
+
+// originalPostMiddleware replaces a repost in the context with the post it
+// points at, for the routes that act on the reposted post: comments,
+// reactions, bookmarks, reposts and the edit history belong to the original.
+func (app *application) originalPostMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		post := getPostFromCtx(r)
+		if post.RepostOfID == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+
+		original, err := app.store.Posts.GetbyID(ctx, *post.RepostOfID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, postCtx, original)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func getPostFromCtx(r *http.Request) *store.Posts {
 	post, _ := r.Context().Value(postCtx).(*store.Posts)
 	return post
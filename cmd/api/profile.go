@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"go-project/internal/store"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type UpdateUserPayload struct {
+	Username    *string `json:"username" validate:"omitempty,username"`
+	DisplayName *string `json:"display_name" validate:"omitempty,max=50"`
+	Bio         *string `json:"bio" validate:"omitempty,max=160"`
+	Website     *string `json:"website" validate:"omitempty,http_url,max=255"`
+	Location    *string `json:"location" validate:"omitempty,max=50"`
+	Pronouns    *string `json:"pronouns" validate:"omitempty,max=30"`
+	IsPrivate   *bool   `json:"is_private"`
+}
+
+// UpdateMe godoc
+//
+//	@Summary		Updates the authenticated user
+//	@Description	Updates the profile and account settings of the authenticated user. Usernames are made of ASCII letters, digits and underscores. The username can be changed once per cooldown period and links to the old username keep working. Making a private account public approves its pending follow requests.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateUserPayload	true	"User payload"
+//	@Success		200		{object}	store.Users
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Username taken or changed too recently"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateMeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateUserPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := *getUserCtx(r)
+
+	if payload.hasProfile() {
+		setIfPresent(&user.Username, payload.Username)
+		setIfPresent(&user.DisplayName, payload.DisplayName)
+		setIfPresent(&user.Bio, payload.Bio)
+		setIfPresent(&user.Website, payload.Website)
+		setIfPresent(&user.Location, payload.Location)
+		setIfPresent(&user.Pronouns, payload.Pronouns)
+
+		if err := app.store.Users.UpdateProfile(ctx, &user, app.config.profile.usernameCooldown); err != nil {
+			switch err {
+			case store.ErrDuplicateUsername, store.ErrUsernameCooldown:
+				app.conflictErr(w, r, err)
+			case store.ErrNotFound:
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	if payload.IsPrivate != nil && *payload.IsPrivate != user.IsPrivate {
+		if err := app.store.Users.SetPrivate(ctx, user.ID, *payload.IsPrivate); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	app.invalidateUser(ctx, user.ID)
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUserByUsername godoc
+//
+//	@Summary		Fetches a user profile by username
+//	@Description	Fetches a user profile by its username. An old username of a renamed user redirects to the current one.
+//	@Tags			users
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Success		200			{object}	store.Profile
+//	@Success		301			{string}	string	"Redirect to the current username"
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/by-username/{username} [get]
+func (app *application) getUserByUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	ctx := r.Context()
+	viewer := getUserCtx(r)
+
+	userID, current, err := app.store.Users.ResolveUsername(ctx, username)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		app.notFoundError(w, r, store.ErrNotFound)
+		return
+	}
+
+	if current != username {
+		http.Redirect(w, r, "/v1/users/by-username/"+url.PathEscape(current), http.StatusMovedPermanently)
+		return
+	}
+
+	profile, err := app.store.Users.GetProfile(ctx, userID, viewer.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (p UpdateUserPayload) hasProfile() bool {
+	return p.Username != nil || p.DisplayName != nil || p.Bio != nil ||
+		p.Website != nil || p.Location != nil || p.Pronouns != nil
+}
+
+func setIfPresent(dst *string, src *string) {
+	if src != nil {
+		*dst = *src
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go-project/internal/realtime"
+	"go-project/internal/store"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AddReaction godoc
+//
+//	@Summary		Reacts to a post or comment
+//	@Description	Adds a reaction of the given kind; reacting twice with the same kind is a no-op
+//	@Tags			reactions
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			kind	path		string	true	"Reaction kind"	Enums(like, love, haha, wow, sad, angry)
+//	@Success		204		{string}	string	"Reaction added"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions/{kind} [put]
+//	@Router			/posts/{postID}/comments/{commentID}/reactions/{kind} [put]
+func (app *application) addReactionHandler(w http.ResponseWriter, r *http.Request) {
+	reaction, err := reactionFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := app.store.Reactions.Add(r.Context(), reaction); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	n := store.Notification{
+		UserID:  post.UserID,
+		ActorID: reaction.UserID,
+		Kind:    store.NotificationReaction,
+		PostID:  &post.ID,
+	}
+	if comment := getCommentFromCtx(r); comment != nil {
+		n.UserID = int64(comment.UserID)
+		n.CommentID = &comment.ID
+	}
+	go app.notify(n)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+
+		app.publish(ctx, realtime.EventReactionAdded, reaction, n.UserID)
+	}()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveReaction godoc
+//
+//	@Summary		Removes a reaction from a post or comment
+//	@Description	Removes a reaction of the given kind; removing a missing reaction is a no-op
+//	@Tags			reactions
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			kind	path		string	true	"Reaction kind"	Enums(like, love, haha, wow, sad, angry)
+//	@Success		204		{string}	string	"Reaction removed"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions/{kind} [delete]
+//	@Router			/posts/{postID}/comments/{commentID}/reactions/{kind} [delete]
+func (app *application) removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	reaction, err := reactionFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := app.store.Reactions.Remove(r.Context(), reaction); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reactionFromRequest builds the reaction of the current user to the
+// comment in the context, or to the post when there is no comment.
+func reactionFromRequest(r *http.Request) (*store.Reaction, error) {
+	kind := chi.URLParam(r, "kind")
+	if !store.IsReactionKind(kind) {
+		return nil, fmt.Errorf("unknown reaction %q, expected one of %s", kind, strings.Join(store.ReactionKinds, ", "))
+	}
+
+	reaction := &store.Reaction{
+		UserID: getUserCtx(r).ID,
+		Kind:   kind,
+	}
+
+	if comment := getCommentFromCtx(r); comment != nil {
+		reaction.CommentID = &comment.ID
+		return reaction, nil
+	}
+
+	postID := getPostFromCtx(r).ID
+	reaction.PostID = &postID
+
+	return reaction, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go-project/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var errNotRestorable = errors.New("nothing to restore, or the grace window is over")
+
+// RestorePost godoc
+//
+//	@Summary		Restores a deleted post
+//	@Description	Undoes the deletion of a post. Authors and moderators can restore posts within the grace window after their deletion.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Posts
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/restore [post]
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deleted := getPostFromCtx(r)
+
+	if err := app.store.Posts.Restore(ctx, deleted.ID, app.config.retention.restoreWindow); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, errNotRestorable)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	post, err := app.store.Posts.GetbyID(ctx, deleted.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// deleting the post dropped it from the timelines
+	if post.Status == store.StatusPublished {
+		go app.fanOutPost(post)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteMe godoc
+//
+//	@Summary		Deletes the authenticated user's account
+//	@Description	Deletes the account of the authenticated user. The account and its content are hidden right away and removed for good once the retention period is over; until the grace window ends an administrator can restore it. The access token of the request and all refresh tokens of the account are revoked.
+//	@Tags			users
+//	@Success		204	{string}	string	"Account deleted"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *application) deleteMeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserCtx(r)
+
+	if err := app.store.Users.SoftDelete(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// a cached copy would keep authenticating the deleted user
+	app.invalidateUser(ctx, user.ID)
+
+	claims := getClaimsCtx(r)
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// restoring the account must not bring back the token that deleted it
+	jti, _ := claims["jti"].(string)
+	if err := app.denyToken(ctx, jti, exp.Time); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreUser godoc
+//
+//	@Summary		Restores a deleted account
+//	@Description	Undoes the deletion of an account within the grace window after its deletion. Only administrators can restore accounts.
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Account restored"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/restore [post]
+func (app *application) restoreUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	permitted, err := app.checkRoleProcedence(ctx, getUserCtx(r), "admin")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !permitted {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if err := app.store.Users.Restore(ctx, userID, app.config.retention.restoreWindow); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, errNotRestorable)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deletedPostsContextMiddleware puts the soft deleted post of the URL in the
+// context, so that the ownership checks of RoleBasedAuthMiddleware apply to
+// it.
+func (app *application) deletedPostsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		post, err := app.store.Posts.GetDeleted(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, postCtx, post)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// runPurger removes soft deleted posts and accounts for good once their
+// retention period is over, along with stale unattached uploads, until ctx
+// is done. Purging is idempotent, so
+// every API replica runs it.
+func (app *application) runPurger(ctx context.Context) {
+	ticker := time.NewTicker(app.config.retention.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.purge(ctx)
+		}
+	}
+}
+
+// purge removes the posts and accounts deleted more than the retention
+// period ago and the uploads never attached to a post, then the blobs of
+// their media. Expired entries of the token denylist go as well.
+func (app *application) purge(ctx context.Context) {
+	retention := app.config.retention
+
+	for _, p := range []struct {
+		purge func(context.Context, time.Duration) ([]string, error)
+		age   time.Duration
+	}{
+		{app.store.Posts.Purge, retention.period},
+		{app.store.Users.Purge, retention.period},
+		{app.store.Media.PurgeUnattached, retention.unattachedMedia},
+	} {
+		keys, err := p.purge(ctx, p.age)
+		if err != nil {
+			app.logger.Errorw("error purging deleted content", "error", err)
+			continue
+		}
+
+		app.deleteBlobs(ctx, keys...)
+	}
+
+	if err := app.store.Tokens.PurgeExpired(ctx); err != nil {
+		app.logger.Errorw("error purging expired revoked tokens", "error", err)
+	}
+}
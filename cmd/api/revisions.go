@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"go-project/internal/store"
+	"go-project/internal/textdiff"
+	"net/http"
+	"strconv"
+)
+
+type PostRevisionsResponse struct {
+	Revisions []store.PostRevision `json:"revisions"`
+	// Diff is left out when the post has a single revision.
+	Diff *RevisionDiff `json:"diff,omitempty"`
+}
+
+// RevisionDiff lists the changes made to the title and the content of a
+// post between two of its versions.
+type RevisionDiff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Title   []textdiff.Op `json:"title"`
+	Content []textdiff.Op `json:"content"`
+}
+
+// GetPostRevisions godoc
+//
+//	@Summary		Fetches the edit history of a post
+//	@Description	Fetches the revisions of a post, newest first, with a word level diff between the versions from and to. Without from and to the latest version is compared to the one before it. Only the author and moderators can read the history of a post.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			from	query		int	false	"Version to compare from"
+//	@Param			to		query		int	false	"Version to compare to"
+//	@Success		200		{object}	PostRevisionsResponse
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions [get]
+func (app *application) getPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	revisions, err := app.store.Revisions.GetByPostID(r.Context(), getPostFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := PostRevisionsResponse{Revisions: revisions}
+
+	if len(revisions) > 1 {
+		// revisions are sorted newest first
+		from, to := revisions[1].Version, revisions[0].Version
+
+		if from, err = readVersion(r, "from", from); err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		if to, err = readVersion(r, "to", to); err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		response.Diff, err = diffRevisions(revisions, from, to)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// readVersion reads the version in the query parameter param, falling back
+// to def when it is not set.
+func readVersion(r *http.Request, param string, def int) (int, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return def, nil
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", param, err)
+	}
+
+	return version, nil
+}
+
+// diffRevisions compares the revisions with versions from and to.
+func diffRevisions(revisions []store.PostRevision, from, to int) (*RevisionDiff, error) {
+	var a, b *store.PostRevision
+	for i := range revisions {
+		if revisions[i].Version == from {
+			a = &revisions[i]
+		}
+		if revisions[i].Version == to {
+			b = &revisions[i]
+		}
+	}
+
+	if a == nil {
+		return nil, fmt.Errorf("version %d does not exist", from)
+	}
+	if b == nil {
+		return nil, fmt.Errorf("version %d does not exist", to)
+	}
+
+	return &RevisionDiff{
+		From:    from,
+		To:      to,
+		Title:   textdiff.Diff(a.Title, b.Title),
+		Content: textdiff.Diff(a.Content, b.Content),
+	}, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go-project/internal/store"
+	"net/http"
+	"time"
+)
+
+var (
+	errPublishAtRequired   = errors.New("publish_at is required to schedule a post")
+	errPublishAtPast       = errors.New("publish_at must be in the future")
+	errPublishAtNotAllowed = errors.New("publish_at can only be set on scheduled posts")
+	errAlreadyPublished    = errors.New("published posts cannot be turned back into drafts or scheduled")
+)
+
+// postStatus works out the status of a post from the requested status and
+// publish time. Posts with a publish time and no status are scheduled and
+// posts with neither are published right away.
+func postStatus(status string, publishAt *time.Time, now time.Time) (string, error) {
+	if status == "" {
+		status = store.StatusPublished
+		if publishAt != nil {
+			status = store.StatusScheduled
+		}
+	}
+
+	switch {
+	case status == store.StatusScheduled && publishAt == nil:
+		return "", errPublishAtRequired
+	case status == store.StatusScheduled && !publishAt.After(now):
+		return "", errPublishAtPast
+	case status != store.StatusScheduled && publishAt != nil:
+		return "", errPublishAtNotAllowed
+	}
+
+	return status, nil
+}
+
+// announcePost fans a post that was just published out to timelines and
+// notifies the users it mentions.
+func (app *application) announcePost(post *store.Posts) {
+	go app.fanOutPost(post)
+	go app.notifyMentions(store.Notification{ActorID: post.UserID, PostID: &post.ID}, post.Mentions, nil)
+}
+
+// GetDrafts godoc
+//
+//	@Summary		Fetches the user's drafts
+//	@Description	Fetches the drafts of the authenticated user, newest first
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	[]store.PostswithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/drafts [get]
+func (app *application) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	app.unpublishedPostsResponse(w, r, store.StatusDraft)
+}
+
+// GetScheduledPosts godoc
+//
+//	@Summary		Fetches the user's scheduled posts
+//	@Description	Fetches the posts the authenticated user scheduled for publishing, newest first
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor returned as next_cursor by the previous page"
+//	@Success		200		{object}	[]store.PostswithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/scheduled [get]
+func (app *application) getScheduledPostsHandler(w http.ResponseWriter, r *http.Request) {
+	app.unpublishedPostsResponse(w, r, store.StatusScheduled)
+}
+
+func (app *application) unpublishedPostsResponse(w http.ResponseWriter, r *http.Request, status string) {
+	pq, err := store.PaginatedQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	pq.Cursor, err = app.readCursor(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	posts, err := app.store.Posts.GetUnpublished(r.Context(), getUserCtx(r).ID, status, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var next *store.Cursor
+	if len(posts) > 0 {
+		last := posts[len(posts)-1]
+		next = nextCursor(len(posts), pq.Limit, last.CreatedAt, last.ID)
+	}
+
+	if err := app.paginatedResponse(w, r, http.StatusOK, posts, next); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CancelScheduledPost godoc
+//
+//	@Summary		Cancels a scheduled post
+//	@Description	Turns a scheduled post back into a draft
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Posts
+//	@Header			200		{string}	ETag	"ETag of the draft, as served when fetching it"
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/schedule [delete]
+func (app *application) cancelScheduledPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if post.Status != store.StatusScheduled {
+		app.conflictErr(w, r, errors.New("post is not scheduled"))
+		return
+	}
+
+	post.Status = store.StatusDraft
+	post.PublishAt = nil
+
+	// a post published by the scheduler in the meantime no longer has the
+	// version that was read
+	if err := app.store.Posts.UpdatebyID(r.Context(), post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.conflictErr(w, r, errors.New("post is not scheduled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.setPostETag(w, r, post.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// runScheduler publishes scheduled posts as they become due until ctx is
+// done. Every API replica runs a scheduler; the store makes sure that each
+// post is published by exactly one of them.
+func (app *application) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(app.config.scheduler.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.publishDuePosts(ctx)
+		}
+	}
+}
+
+// publishDuePosts publishes the due scheduled posts in batches and
+// announces each of them.
+func (app *application) publishDuePosts(ctx context.Context) {
+	for {
+		posts, err := app.store.Posts.PublishDue(ctx, app.config.scheduler.batchSize)
+		if err != nil {
+			app.logger.Errorw("error publishing scheduled posts", "error", err)
+			return
+		}
+
+		for i := range posts {
+			app.logger.Infow("published scheduled post", "post", posts[i].ID, "user", posts[i].UserID)
+			app.announcePost(&posts[i])
+		}
+
+		if len(posts) < app.config.scheduler.batchSize {
+			return
+		}
+	}
+}
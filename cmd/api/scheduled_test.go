@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPostStatus(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+
+	tests := []struct {
+		status    string
+		publishAt *time.Time
+		want      string
+		err       error
+	}{
+		{"", nil, "published", nil},
+		{"", &future, "scheduled", nil},
+		{"draft", nil, "draft", nil},
+		{"published", nil, "published", nil},
+		{"scheduled", &future, "scheduled", nil},
+		{"scheduled", nil, "", errPublishAtRequired},
+		{"scheduled", &past, "", errPublishAtPast},
+		{"", &now, "", errPublishAtPast},
+		{"draft", &future, "", errPublishAtNotAllowed},
+	}
+
+	for _, tt := range tests {
+		got, err := postStatus(tt.status, tt.publishAt, now)
+		if got != tt.want || err != tt.err {
+			t.Errorf("postStatus(%q, %v) = %q, %v, want %q, %v", tt.status, tt.publishAt, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"go-project/internal/store"
+	"net/http"
+)
+
+// Search godoc
+//
+//	@Summary		Searches posts or users
+//	@Description	Searches posts by title, content and tags, most relevant first, or users by username and display name. Post queries support "exact phrases", prefix* matches, -exclusions and OR. Snippets are HTML-escaped with matches wrapped in <mark>.
+//	@Tags			search
+//	@Produce		json
+//	@Param			q		query		string	true	"Search query"
+//	@Param			type	query		string	false	"posts (default) or users"
+//	@Param			author	query		string	false	"Username of the author of the posts"
+//	@Param			tags	query		string	false	"Comma separated tags the posts must have"
+//	@Param			since	query		string	false	"Posts created at or after"
+//	@Param			until	query		string	false	"Posts created at or before"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.PostSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search [get]
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	sq, err := store.SearchQuery{Type: store.SearchPosts, Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(sq); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	viewer := getUserCtx(r)
+
+	var results any
+	switch sq.Type {
+	case store.SearchUsers:
+		results, err = app.store.Search.Users(ctx, viewer.ID, sq)
+	default:
+		results, err = app.store.Search.Posts(ctx, viewer.ID, sq)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrEmptySearch):
+			app.badRequest(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
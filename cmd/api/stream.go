@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/internal/realtime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/net/websocket"
+)
+
+var errForbiddenOrigin = errors.New("origin not allowed")
+
+// streamHeartbeat is how often an idle stream is written to so that
+// proxies and clients do not consider it dead.
+const streamHeartbeat = time.Second * 25
+
+// isStreamRequest reports whether r opens a long lived event stream, which
+// must not be cut by the request timeout.
+func isStreamRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/v1/stream")
+}
+
+// skipForStreams applies mw to every request except event streams.
+func skipForStreams(mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isStreamRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// StreamEvents godoc
+//
+//	@Summary		Streams real-time events
+//	@Description	Streams new posts, comments, reactions and notifications of the user as Server-Sent Events. Reconnecting with Last-Event-ID replays the events missed in between, as long as they are still buffered. The stream ends when the access token expires or is revoked.
+//	@Tags			stream
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header		int		false	"Id of the last event received"
+//	@Param			last_event_id	query		int		false	"Id of the last event received, for clients that cannot set headers"
+//	@Success		200				{string}	string	"Event stream"
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream [get]
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	lastEventID, err := readLastEventID(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	if err := clearWriteDeadline(rc); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user := getUserCtx(r)
+	sub := app.events.Subscribe(user.ID, lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		app.logger.Warnw("streaming is not supported", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	expiry := time.NewTimer(time.Until(tokenExpiry(getClaimsCtx(r))))
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-expiry.C:
+			return
+		case <-heartbeat.C:
+			if !app.streamAuthorized(r) {
+				return
+			}
+
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.Events():
+			if !ok {
+				// the client fell behind, it resumes on reconnect
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// streamMessage is the WebSocket representation of an event.
+type streamMessage struct {
+	ID   int64           `json:"id,omitempty"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// StreamEventsWebSocket godoc
+//
+//	@Summary		Streams real-time events over a WebSocket
+//	@Description	WebSocket variant of /stream. Every event is sent as a JSON message with id, type and data; heartbeats are sent as messages of type ping. Only the frontend origin may open it from a browser, and it is closed when the access token expires or is revoked.
+//	@Tags			stream
+//	@Param			last_event_id	query		int		false	"Id of the last event received"
+//	@Success		101				{string}	string	"Switching protocols"
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream/ws [get]
+func (app *application) streamWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	lastEventID, err := readLastEventID(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := clearWriteDeadline(http.NewResponseController(w)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user := getUserCtx(r)
+
+	server := websocket.Server{
+		// in browsers only the frontend may open the stream, clients that
+		// are not browsers send no origin
+		Handshake: func(config *websocket.Config, _ *http.Request) error {
+			if config.Origin != nil && config.Origin.String() != app.config.allowedOrigin {
+				return errForbiddenOrigin
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			sub := app.events.Subscribe(user.ID, lastEventID)
+			defer sub.Close()
+
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+
+			// incoming messages are ignored, reading only detects the
+			// client going away
+			go func() {
+				defer cancel()
+
+				var discard string
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+			}()
+
+			heartbeat := time.NewTicker(streamHeartbeat)
+			defer heartbeat.Stop()
+
+			expiry := time.NewTimer(time.Until(tokenExpiry(getClaimsCtx(r))))
+			defer expiry.Stop()
+
+			for {
+				var msg streamMessage
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-expiry.C:
+					return
+				case <-heartbeat.C:
+					if !app.streamAuthorized(r) {
+						return
+					}
+					msg = streamMessage{Type: "ping"}
+				case e, ok := <-sub.Events():
+					if !ok {
+						return
+					}
+					msg = streamMessage{ID: e.ID, Type: e.Type, Data: e.Data}
+				}
+
+				if err := websocket.JSON.Send(ws, msg); err != nil {
+					return
+				}
+			}
+		},
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// tokenExpiry is the expiry time of the access token with the given claims.
+func tokenExpiry(claims jwt.MapClaims) time.Time {
+	exp, _ := claims["exp"].(float64)
+	return time.Unix(int64(exp), 0)
+}
+
+// streamAuthorized reports whether the stream opened by r may go on: its
+// access token was not revoked and the account was not deleted since. It
+// is checked on every heartbeat.
+func (app *application) streamAuthorized(r *http.Request) bool {
+	ctx := r.Context()
+
+	user, err := app.getUser(ctx, getUserCtx(r).ID)
+	if err != nil {
+		return false
+	}
+
+	revoked, err := app.isTokenRevoked(ctx, getClaimsCtx(r), user)
+	if err != nil {
+		app.logger.Warnw("error checking stream token", "user", user.ID, "error", err)
+		return false
+	}
+
+	return !revoked
+}
+
+func readLastEventID(r *http.Request) (int64, error) {
+	param := r.Header.Get("Last-Event-ID")
+	if param == "" {
+		param = r.URL.Query().Get("last_event_id")
+	}
+
+	if param == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid last event id: %w", err)
+	}
+
+	return id, nil
+}
+
+// clearWriteDeadline lifts the server's write timeout for a stream.
+func clearWriteDeadline(rc *http.ResponseController) error {
+	err := rc.SetWriteDeadline(time.Time{})
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
+// publish pushes an event to the connected clients of users. Failures are
+// only logged as clients can always catch up by polling.
+func (app *application) publish(ctx context.Context, kind string, data any, users ...int64) {
+	if len(users) == 0 {
+		return
+	}
+
+	e, err := realtime.NewEvent(kind, data, users...)
+	if err == nil {
+		err = app.events.Publish(ctx, e)
+	}
+
+	if err != nil {
+		app.logger.Errorw("error publishing event", "type", kind, "error", err)
+	}
+}
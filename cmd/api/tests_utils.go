@@ -2,7 +2,9 @@ package main
 
 import (
 	"go-project/internal/auth"
+	"go-project/internal/cursor"
 	"go-project/internal/ratelimiter"
+	"go-project/internal/realtime"
 	"go-project/internal/store"
 	"go-project/internal/store/cache"
 	"net/http"
@@ -32,6 +34,8 @@ func newTestApplication(t *testing.T, cfg servConfig) *application {
 		cacheStorage: mockCacheStorage,
 		authenticator: testAuth,
 		ratelimiter: rateLimiter,
+		cursor: cursor.NewSigner("test"),
+		events: realtime.NewLocalBroker(realtime.NewHub(0)),
 	}
 }
 
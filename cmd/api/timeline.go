@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"go-project/internal/realtime"
+	"go-project/internal/store"
+	"time"
+)
+
+// timelineTimeout bounds the background work done on timelines after a
+// request has been answered.
+const timelineTimeout = time.Second * 10
+
+// getFeed serves the feed from the user's Redis timeline when possible and
+// from Postgres otherwise. Timelines only hold the newest part of the
+// unfiltered feed, so once a timeline is exhausted the remaining posts of
+// the page are read from Postgres.
+func (app *application) getFeed(ctx context.Context, userID int64, fq store.PaginatedFeed) ([]store.PostswithMetadata, error) {
+	if !app.timelineEligible(fq) {
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	// posts of popular users are not fanned out, their followers are
+	// always served from Postgres
+	popular, err := app.store.Followers.FollowsPopular(ctx, userID, app.config.timeline.popularThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	if popular {
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	// posts are not fanned out to the followers of their tags either
+	followsTags, err := app.store.Hashtags.FollowsAny(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if followsTags {
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	ids, ok, err := app.cacheStorage.Timelines.Get(ctx, userID, fq.Cursor, fq.Limit)
+	if err != nil {
+		app.logger.Warnw("error reading timeline, falling back to postgres", "user", userID, "error", err)
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	if !ok {
+		feed, err := app.store.Posts.GetUserFeed(ctx, userID, fq)
+		if err != nil {
+			return nil, err
+		}
+
+		// the first page is the head of the feed, which is exactly what a
+		// timeline holds, so it is used to warm the cold timeline
+		if fq.Cursor == nil {
+			app.warmTimeline(ctx, userID, feed)
+		}
+
+		return feed, nil
+	}
+
+	feed, err := app.store.Posts.GetFeedByIDs(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(feed) >= fq.Limit {
+		return feed, nil
+	}
+
+	rest := fq
+	rest.Limit = fq.Limit - len(feed)
+	if len(feed) > 0 {
+		last := feed[len(feed)-1].Cursor()
+		rest.Cursor = &last
+	}
+
+	older, err := app.store.Posts.GetUserFeed(ctx, userID, rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(feed, older...), nil
+}
+
+// timelineEligible reports whether a feed request asks for the plain newest
+// first feed that timelines hold.
+func (app *application) timelineEligible(fq store.PaginatedFeed) bool {
+	return app.config.redis.enabled &&
+		fq.Sort == "desc" &&
+		fq.Offset == 0 &&
+		len(fq.Tags) == 0 &&
+		fq.Search == "" &&
+		fq.Since == "" &&
+		fq.Until == ""
+}
+
+func (app *application) warmTimeline(ctx context.Context, userID int64, feed []store.PostswithMetadata) {
+	entries := make([]store.Cursor, len(feed))
+	for i, post := range feed {
+		entries[i] = post.Cursor()
+	}
+
+	if err := app.cacheStorage.Timelines.Push(ctx, []int64{userID}, entries...); err != nil {
+		app.logger.Warnw("error warming timeline", "user", userID, "error", err)
+	}
+}
+
+// fanOutPost pushes a new post or repost to the timelines and the event
+// streams of its author and of the users it is visible to: the author's
+// followers, unless the author is popular, or the mentioned users of a
+// mentioned-only post. It is meant to run in its own goroutine.
+func (app *application) fanOutPost(post *store.Posts) {
+	ctx, cancel := context.WithTimeout(context.Background(), timelineTimeout)
+	defer cancel()
+
+	recipients := []int64{post.UserID}
+	switch post.Visibility {
+	case store.VisibilityPrivate:
+	case store.VisibilityMentioned:
+		for _, m := range post.Mentions {
+			recipients = append(recipients, m.UserID)
+		}
+	default:
+		followers, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID)
+		if err != nil {
+			app.logger.Errorw("error fanning out post", "post", post.ID, "error", err)
+			return
+		}
+
+		if len(followers) < app.config.timeline.popularThreshold {
+			recipients = append(recipients, followers...)
+		}
+	}
+
+	app.publish(ctx, realtime.EventPostCreated, post, recipients...)
+
+	if !app.config.redis.enabled {
+		return
+	}
+
+	entry := store.Cursor{CreatedAt: post.CreatedAt, ID: post.ID}
+	if err := app.cacheStorage.Timelines.Push(ctx, recipients, entry); err != nil {
+		app.logger.Errorw("error fanning out post", "post", post.ID, "error", err)
+	}
+}
+
+// removeFromTimelines drops a deleted post or repost from every timeline it was
+// fanned out to. It is meant to run in its own goroutine.
+func (app *application) removeFromTimelines(post *store.Posts) {
+	if !app.config.redis.enabled {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timelineTimeout)
+	defer cancel()
+
+	followers, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID)
+	if err != nil {
+		app.logger.Errorw("error removing post from timelines", "post", post.ID, "error", err)
+		return
+	}
+
+	if err := app.cacheStorage.Timelines.Remove(ctx, append(followers, post.UserID), post.ID); err != nil {
+		app.logger.Errorw("error removing post from timelines", "post", post.ID, "error", err)
+	}
+}
+
+// invalidateTimeline drops the timeline of a user whose set of followed
+// users changed; it is rebuilt from Postgres on the next read.
+func (app *application) invalidateTimeline(ctx context.Context, userID int64) {
+	if !app.config.redis.enabled {
+		return
+	}
+
+	if err := app.cacheStorage.Timelines.Delete(ctx, userID); err != nil {
+		app.logger.Errorw("error invalidating timeline", "user", userID, "error", err)
+	}
+}
@@ -15,12 +15,12 @@ const userCtx userKey = "user"
 // GetUser godoc
 //
 //	@Summary		Fetches a user profile
-//	@Description	Fetches a user profile by ID
+//	@Description	Fetches a user profile by ID with its follower, following and post counts and its relationship to the viewer. Users who blocked the viewer, or were blocked by them, are not found.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"User ID"
-//	@Success		200	{object}	store.Users
+//	@Success		200	{object}	store.Profile
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
@@ -35,8 +35,19 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, getUserCtx(r).ID, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		app.notFoundError(w, r, store.ErrNotFound)
+		return
+	}
 	
-	user, err := app.getUser(ctx, userID)
+	profile, err := app.store.Users.GetProfile(ctx, userID, getUserCtx(r).ID)
 	if err != nil{
 		switch err{
 		case store.ErrNotFound:
@@ -48,7 +59,7 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -58,13 +69,15 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 // FollowUser godoc
 //
 //	@Summary		Follows a user
-//	@Description	Follows a user by ID
+//	@Description	Follows a user by ID. Following a private account sends a follow request instead.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int		true	"User ID"
+//	@Success		202		{object}	map[string]string	"Follow requested"
 //	@Success		204		{string}	string	"User followed"
 //	@Failure		400		{object}	error	"User payload missing"
+//	@Failure		403		{object}	error	"User is blocked"
 //	@Failure		404		{object}	error	"User not found"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/follow [put]
@@ -79,11 +92,18 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 	}
 	ctx := r.Context()
 
-	if err := app.store.Followers.Follow(ctx, followerUser.ID, followedUser); err != nil {
+	requested, err := app.store.Followers.Follow(ctx, followerUser.ID, followedUser)
+	if err != nil {
 		switch err{
 		case store.ErrConflict:
 			app.conflictErr(w, r, err)
 			return
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+			return
+		case store.ErrBlocked:
+			app.forbiddenResponse(w, r)
+			return
 		default:
 			app.internalServerError(w, r, err)
 			return
@@ -91,6 +111,27 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 
 	}
 
+	if requested {
+		go app.notify(store.Notification{
+			UserID:  followedUser,
+			ActorID: followerUser.ID,
+			Kind:    store.NotificationFollowRequest,
+		})
+
+		if err := app.jsonResponse(w, http.StatusAccepted, map[string]string{"status": "requested"}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateTimeline(ctx, followerUser.ID)
+
+	go app.notify(store.Notification{
+		UserID:  followedUser,
+		ActorID: followerUser.ID,
+		Kind:    store.NotificationFollow,
+	})
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -100,7 +141,7 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 // UnfollowUser gdoc
 //
 //	@Summary		Unfollow a user
-//	@Description	Unfollow a user by ID
+//	@Description	Unfollow a user by ID, or cancel a pending follow request
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -124,6 +165,8 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.invalidateTimeline(ctx, Unfolloweduser.ID)
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
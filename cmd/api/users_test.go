@@ -4,8 +4,11 @@ import (
 	"log"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 func TestGetUser(t *testing.T) {
@@ -13,7 +16,11 @@ func TestGetUser(t *testing.T) {
 	app := newTestApplication(t,config)
 	mux := app.mount().(*chi.Mux)
 
-	testToken, err :=  app.authenticator.GenerateToken(nil)
+	testToken, err :=  app.authenticator.GenerateToken(jwt.MapClaims{
+		"sub": int64(1),
+		"jti": uuid.NewString(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -39,10 +46,9 @@ func TestGetUser(t *testing.T) {
 			t.Fatal(err)
 
 		}
+		req.Header.Set("Authorization", "Bearer " + testToken)
 		rr := executor(req, mux)
 
-
-		req.Header.Set("Authorization", "Bearer " + testToken)
 		checkResponseCode(t, http.StatusOK, rr.Code)
 
 		log.Println(rr.Body)
@@ -0,0 +1,10 @@
+-- a repost is a post without content of its own pointing at the reposted
+-- post and goes away with it, a quote post keeps its content when the
+-- quoted post is deleted
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS repost_of_id bigint REFERENCES posts(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS quote_of_id bigint REFERENCES posts(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_id_repost_of_id ON posts(user_id, repost_of_id) WHERE repost_of_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_repost_of_id ON posts(repost_of_id);
+CREATE INDEX IF NOT EXISTS idx_posts_quote_of_id ON posts(quote_of_id);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS notifications(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('follow', 'comment', 'reply', 'mention', 'reaction', 'repost')),
+    post_id bigint REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id bigint REFERENCES comments(id) ON DELETE CASCADE,
+    read_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id_created_at ON notifications(user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id_unread ON notifications(user_id) WHERE read_at IS NULL;
+
+-- follows, reactions and reposts notify once per actor and target, however
+-- often they are undone and redone
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_once ON notifications(user_id, actor_id, kind, COALESCE(post_id, 0), COALESCE(comment_id, 0))
+    WHERE kind IN ('follow', 'reaction', 'repost');
+
+-- a missing row means the kind is enabled
+CREATE TABLE IF NOT EXISTS notification_preferences(
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+
+    PRIMARY KEY(user_id, kind)
+);
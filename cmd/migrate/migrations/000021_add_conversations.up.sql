@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS conversations(
+    id bigserial PRIMARY KEY,
+    created_by bigint REFERENCES users(id) ON DELETE SET NULL,
+    is_group BOOLEAN NOT NULL DEFAULT FALSE,
+    title VARCHAR(100),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    -- updated_at moves with every message so conversations sort by activity
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- members that are not mutual followers of the creator join as pending and
+-- only take part once they accept the request
+CREATE TABLE IF NOT EXISTS conversation_members(
+    conversation_id bigint NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL DEFAULT 'accepted' CHECK (status IN ('accepted', 'pending')),
+    muted BOOLEAN NOT NULL DEFAULT FALSE,
+    last_read_message_id bigint,
+    joined_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(conversation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_members_user_id ON conversation_members(user_id, status);
+
+CREATE TABLE IF NOT EXISTS messages(
+    id bigserial PRIMARY KEY,
+    conversation_id bigint NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id bigint REFERENCES users(id) ON DELETE SET NULL,
+    content TEXT NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages(conversation_id, id DESC);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS user_blocks(
+    blocker_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks(blocked_id);
+
+CREATE TABLE IF NOT EXISTS user_mutes(
+    muter_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    muted_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(muter_id, muted_id),
+    CHECK (muter_id <> muted_id)
+);
@@ -0,0 +1,9 @@
+DELETE FROM notifications WHERE kind = 'follow_request';
+
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_kind_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_kind_check
+    CHECK (kind IN ('follow', 'comment', 'reply', 'mention', 'reaction', 'repost'));
+
+DROP TABLE IF EXISTS follow_requests;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_private;
@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- a follow of a private user waits here until the user approves it
+CREATE TABLE IF NOT EXISTS follow_requests(
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    follower_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(user_id, follower_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_requests_user_id_created_at ON follow_requests(user_id, created_at DESC, follower_id DESC);
+
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_kind_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_kind_check
+    CHECK (kind IN ('follow', 'follow_request', 'comment', 'reply', 'mention', 'reaction', 'repost'));
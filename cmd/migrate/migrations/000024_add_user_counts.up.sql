@@ -0,0 +1,51 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS followers_count bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS following_count bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS posts_count bigint NOT NULL DEFAULT 0;
+
+UPDATE users u SET
+    followers_count = (SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id),
+    following_count = (SELECT COUNT(*) FROM followers f WHERE f.follower_id = u.id),
+    posts_count = (SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id AND p.repost_of_id IS NULL);
+
+CREATE OR REPLACE FUNCTION update_follow_counts() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.user_id;
+        UPDATE users SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+        RETURN NEW;
+    END IF;
+
+    UPDATE users SET followers_count = followers_count - 1 WHERE id = OLD.user_id;
+    UPDATE users SET following_count = following_count - 1 WHERE id = OLD.follower_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS followers_update_counts ON followers;
+CREATE TRIGGER followers_update_counts AFTER INSERT OR DELETE ON followers
+    FOR EACH ROW EXECUTE FUNCTION update_follow_counts();
+
+-- reposts do not count as posts of the reposter
+CREATE OR REPLACE FUNCTION update_posts_count() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.repost_of_id IS NULL THEN
+            UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF OLD.repost_of_id IS NULL THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    END IF;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_update_count ON posts;
+CREATE TRIGGER posts_update_count AFTER INSERT OR DELETE ON posts
+    FOR EACH ROW EXECUTE FUNCTION update_posts_count();
+
+CREATE INDEX IF NOT EXISTS idx_followers_user_id_created_at ON followers(user_id, created_at DESC, follower_id DESC);
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id_created_at ON followers(follower_id, created_at DESC, user_id DESC);
@@ -0,0 +1,34 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS display_name varchar(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS bio varchar(160) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS website varchar(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS location varchar(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS pronouns varchar(30) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS username_changed_at timestamp(0) with time zone;
+
+-- usernames address profiles and redirects, so they have to be unique; the
+-- oldest account keeps a shared username and the others get their id
+-- appended to it, again if the new name is taken as well
+DO $$
+BEGIN
+    LOOP
+        UPDATE users u SET username = u.username || '_' || u.id
+        WHERE EXISTS (
+            SELECT 1 FROM users o
+            WHERE o.username = u.username AND (o.created_at, o.id) < (u.created_at, u.id)
+        );
+        EXIT WHEN NOT FOUND;
+    END LOOP;
+END
+$$;
+
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username);
+
+CREATE TABLE IF NOT EXISTS username_history (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    username varchar(255) NOT NULL,
+    changed_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_username ON username_history (username, changed_at DESC);
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS media (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id bigint REFERENCES posts (id) ON DELETE CASCADE,
+    position smallint NOT NULL DEFAULT 0,
+    key varchar(255) NOT NULL,
+    thumbnail_key varchar(255) NOT NULL,
+    url text NOT NULL,
+    thumbnail_url text NOT NULL,
+    content_type varchar(50) NOT NULL,
+    width int NOT NULL,
+    height int NOT NULL,
+    size bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_post_id ON media (post_id, position);
+CREATE INDEX IF NOT EXISTS idx_media_user_id_unattached ON media (user_id) WHERE post_id IS NULL;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS avatar_key varchar(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS avatar_url text NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS tag_follows;
+DROP TABLE IF EXISTS post_hashtags;
+DROP TABLE IF EXISTS hashtags;
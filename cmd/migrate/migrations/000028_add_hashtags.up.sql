@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS hashtags (
+    id bigserial PRIMARY KEY,
+    name varchar(100) NOT NULL UNIQUE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- created_at is the creation time of the post, kept here so that trending
+-- windows only scan this table
+CREATE TABLE IF NOT EXISTS post_hashtags (
+    post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    hashtag_id bigint NOT NULL REFERENCES hashtags (id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (post_id, hashtag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_hashtags_hashtag_id ON post_hashtags (hashtag_id, created_at DESC, post_id DESC);
+CREATE INDEX IF NOT EXISTS idx_post_hashtags_created_at ON post_hashtags (created_at);
+
+CREATE TABLE IF NOT EXISTS tag_follows (
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    hashtag_id bigint NOT NULL REFERENCES hashtags (id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, hashtag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_follows_hashtag_id ON tag_follows (hashtag_id);
+
+-- existing tags are brought to their canonical form and indexed by
+-- make backfill-hashtags, which applies the normalization rules of the API
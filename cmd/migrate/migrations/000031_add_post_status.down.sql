@@ -0,0 +1,29 @@
+CREATE OR REPLACE FUNCTION update_posts_count() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.repost_of_id IS NULL THEN
+            UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF OLD.repost_of_id IS NULL THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    END IF;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_update_count ON posts;
+CREATE TRIGGER posts_update_count AFTER INSERT OR DELETE ON posts
+    FOR EACH ROW EXECUTE FUNCTION update_posts_count();
+
+-- unpublished posts start counting again
+UPDATE users u SET posts_count = (
+    SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id AND p.repost_of_id IS NULL
+);
+
+DROP INDEX IF EXISTS idx_posts_publish_at;
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_publish_at_check;
+ALTER TABLE posts DROP COLUMN IF EXISTS publish_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS status;
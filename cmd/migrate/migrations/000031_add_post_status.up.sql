@@ -0,0 +1,38 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status varchar(20) NOT NULL DEFAULT 'published'
+    CONSTRAINT posts_status_check CHECK (status IN ('draft', 'scheduled', 'published'));
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+
+ALTER TABLE posts ADD CONSTRAINT posts_publish_at_check CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+-- the scheduler polls for scheduled posts that are due
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts(publish_at) WHERE status = 'scheduled';
+
+-- drafts and scheduled posts do not count as posts until they are published
+CREATE OR REPLACE FUNCTION update_posts_count() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.repost_of_id IS NULL AND NEW.status = 'published' THEN
+            UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'UPDATE' THEN
+        IF NEW.repost_of_id IS NULL AND OLD.status <> 'published' AND NEW.status = 'published' THEN
+            UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+        ELSIF NEW.repost_of_id IS NULL AND OLD.status = 'published' AND NEW.status <> 'published' THEN
+            UPDATE users SET posts_count = posts_count - 1 WHERE id = NEW.user_id;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF OLD.repost_of_id IS NULL AND OLD.status = 'published' THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    END IF;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_update_count ON posts;
+CREATE TRIGGER posts_update_count AFTER INSERT OR DELETE OR UPDATE OF status ON posts
+    FOR EACH ROW EXECUTE FUNCTION update_posts_count();
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS posts_record_revision ON posts;
+DROP FUNCTION IF EXISTS record_post_revision();
+DROP TABLE IF EXISTS post_revisions;
@@ -0,0 +1,34 @@
+-- a revision holds a post as it was at one version, the current one included
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    version int NOT NULL,
+    title text NOT NULL,
+    content text NOT NULL,
+    visibility varchar(20) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (post_id, version)
+);
+
+CREATE OR REPLACE FUNCTION record_post_revision() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO post_revisions (post_id, version, title, content, visibility)
+    VALUES (NEW.id, COALESCE(NEW.version, 0), NEW.title, NEW.content, NEW.visibility)
+    ON CONFLICT (post_id, version) DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_record_revision ON posts;
+CREATE TRIGGER posts_record_revision AFTER INSERT OR UPDATE OF version ON posts
+    FOR EACH ROW WHEN (NEW.repost_of_id IS NULL) EXECUTE FUNCTION record_post_revision();
+
+-- updated_at was never maintained, so it only means something for posts
+-- that were never edited
+UPDATE posts SET updated_at = created_at WHERE COALESCE(version, 0) = 0;
+
+-- earlier versions were overwritten, only the current one can be kept
+INSERT INTO post_revisions (post_id, version, title, content, visibility, created_at)
+SELECT id, COALESCE(version, 0), title, content, visibility, updated_at
+FROM posts
+WHERE repost_of_id IS NULL
+ON CONFLICT (post_id, version) DO NOTHING;
@@ -0,0 +1,62 @@
+-- deleted rows must not come back once deleted_at is gone
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+
+DROP TRIGGER IF EXISTS users_deleted_update_counts ON users;
+DROP FUNCTION IF EXISTS update_deleted_user_counts;
+
+CREATE OR REPLACE FUNCTION update_follow_counts() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.user_id;
+        UPDATE users SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+        RETURN NEW;
+    END IF;
+
+    UPDATE users SET followers_count = followers_count - 1 WHERE id = OLD.user_id;
+    UPDATE users SET following_count = following_count - 1 WHERE id = OLD.follower_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION update_posts_count() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.repost_of_id IS NULL AND NEW.status = 'published' THEN
+            UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'UPDATE' THEN
+        IF NEW.repost_of_id IS NULL AND OLD.status <> 'published' AND NEW.status = 'published' THEN
+            UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+        ELSIF NEW.repost_of_id IS NULL AND OLD.status = 'published' AND NEW.status <> 'published' THEN
+            UPDATE users SET posts_count = posts_count - 1 WHERE id = NEW.user_id;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF OLD.repost_of_id IS NULL AND OLD.status = 'published' THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    END IF;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_update_count ON posts;
+CREATE TRIGGER posts_update_count AFTER INSERT OR DELETE OR UPDATE OF status ON posts
+    FOR EACH ROW EXECUTE FUNCTION update_posts_count();
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS fk_user;
+ALTER TABLE posts ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id);
+
+ALTER TABLE comments
+    DROP CONSTRAINT IF EXISTS fk_comments_user,
+    DROP CONSTRAINT IF EXISTS fk_comments_post;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,80 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+-- the purge job looks for rows whose retention period ran out
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- purging a user or a post removes everything that hangs off it
+DELETE FROM comments c WHERE NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id);
+DELETE FROM comments c WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = c.user_id);
+
+ALTER TABLE comments
+    ADD CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS fk_user;
+ALTER TABLE posts ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+-- deleted posts do not count as posts of their author
+CREATE OR REPLACE FUNCTION update_posts_count() RETURNS trigger AS $$
+DECLARE
+    delta int := 0;
+BEGIN
+    IF TG_OP <> 'INSERT' AND OLD.repost_of_id IS NULL AND OLD.status = 'published' AND OLD.deleted_at IS NULL THEN
+        delta := delta - 1;
+    END IF;
+
+    IF TG_OP <> 'DELETE' AND NEW.repost_of_id IS NULL AND NEW.status = 'published' AND NEW.deleted_at IS NULL THEN
+        delta := delta + 1;
+    END IF;
+
+    IF delta <> 0 THEN
+        UPDATE users SET posts_count = posts_count + delta WHERE id = COALESCE(NEW.user_id, OLD.user_id);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_update_count ON posts;
+CREATE TRIGGER posts_update_count AFTER INSERT OR DELETE OR UPDATE OF status, deleted_at ON posts
+    FOR EACH ROW EXECUTE FUNCTION update_posts_count();
+
+-- deleted users are not counted as followers or followed users. The edges
+-- of a deleted user were uncounted when it was deleted, so they are not
+-- uncounted again when the purge removes them.
+CREATE OR REPLACE FUNCTION update_follow_counts() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.user_id;
+        UPDATE users SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+        RETURN NEW;
+    END IF;
+
+    UPDATE users SET followers_count = followers_count - 1 WHERE id = OLD.user_id
+        AND EXISTS (SELECT 1 FROM users f WHERE f.id = OLD.follower_id AND f.deleted_at IS NULL);
+    UPDATE users SET following_count = following_count - 1 WHERE id = OLD.follower_id
+        AND EXISTS (SELECT 1 FROM users f WHERE f.id = OLD.user_id AND f.deleted_at IS NULL);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION update_deleted_user_counts() RETURNS trigger AS $$
+DECLARE
+    delta int := CASE WHEN NEW.deleted_at IS NULL THEN 1 ELSE -1 END;
+BEGIN
+    UPDATE users u SET followers_count = u.followers_count + delta
+    FROM followers f WHERE f.follower_id = NEW.id AND u.id = f.user_id AND u.deleted_at IS NULL;
+
+    UPDATE users u SET following_count = u.following_count + delta
+    FROM followers f WHERE f.user_id = NEW.id AND u.id = f.follower_id AND u.deleted_at IS NULL;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_deleted_update_counts ON users;
+CREATE TRIGGER users_deleted_update_counts AFTER UPDATE OF deleted_at ON users
+    FOR EACH ROW WHEN ((OLD.deleted_at IS NULL) <> (NEW.deleted_at IS NULL))
+    EXECUTE FUNCTION update_deleted_user_counts();
@@ -0,0 +1,3 @@
+-- access tokens issued before this moment are rejected; a password reset
+-- moves it forward to end the sessions that were open
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_valid_after timestamp(0) with time zone;
@@ -0,0 +1,41 @@
+// Package blob stores uploaded files behind a small interface so that the
+// local filesystem can later be swapped for an S3-compatible bucket.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store keeps blobs under slash separated keys such as
+// "media/42/7c1e….jpg".
+type Store interface {
+	// Put stores the content of r under key, replacing any previous blob.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens the blob stored under key. It returns ErrNotFound when there
+	// is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the address clients fetch the blob from.
+	URL(key string) string
+}
+
+// validKey reports whether key is a relative path that stays inside the
+// store, so that it can be mapped onto a directory or a bucket as is.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return false
+	}
+
+	return path.Clean(key) == key && key != "." && !strings.HasPrefix(key, "../") && key != ".."
+}
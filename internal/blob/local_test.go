@@ -0,0 +1,65 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := NewLocalStore(t.TempDir(), "http://localhost:8080/v1/media/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("round trips a blob", func(t *testing.T) {
+		if err := s.Put(ctx, "media/1/a.png", strings.NewReader("data"), "image/png"); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := s.Get(ctx, "media/1/a.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != "data" {
+			t.Errorf("expected %q, got %q", "data", data)
+		}
+
+		if got := s.URL("media/1/a.png"); got != "http://localhost:8080/v1/media/media/1/a.png" {
+			t.Errorf("unexpected url %q", got)
+		}
+	})
+
+	t.Run("deletes a blob", func(t *testing.T) {
+		if err := s.Delete(ctx, "media/1/a.png"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.Get(ctx, "media/1/a.png"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+
+		if err := s.Delete(ctx, "media/1/a.png"); err != nil {
+			t.Errorf("deleting a missing blob should succeed, got %v", err)
+		}
+	})
+
+	t.Run("rejects keys leaving the store", func(t *testing.T) {
+		for _, key := range []string{"", "/etc/passwd", "../secret", "media/../../secret", "media//a", `media\a`} {
+			if err := s.Put(ctx, key, strings.NewReader("x"), "text/plain"); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("key %q: expected ErrInvalidKey, got %v", key, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package cursor
+
+import (
+	"go-project/internal/store"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner("secret")
+	want := store.Cursor{CreatedAt: "2024-07-16T08:28:28Z", ID: 42}
+
+	token, err := signer.Encode(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should decode its own cursors", func(t *testing.T) {
+		got, err := signer.Decode(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if *got != want {
+			t.Errorf("expected cursor %+v, got %+v", want, *got)
+		}
+	})
+
+	t.Run("should reject tampered cursors", func(t *testing.T) {
+		forged, err := NewSigner("other secret").Encode(store.Cursor{CreatedAt: want.CreatedAt, ID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, token := range []string{forged, token + "x", "not-a-cursor", ""} {
+			if _, err := signer.Decode(token); err != ErrInvalidCursor {
+				t.Errorf("expected %v decoding %q, got %v", ErrInvalidCursor, token, err)
+			}
+		}
+	})
+}
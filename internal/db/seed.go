@@ -3,7 +3,6 @@ package db
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"go-project/internal/store"
 	"log"
 	"math/rand"
@@ -49,7 +48,7 @@ func Seed(store store.Storage, db *sql.DB) {
 	followers := generateFollowers(1000, users)
 	
 	for _, follower := range followers{
-		if err := store.Followers.Follow(ctx, follower.FollowerID, follower.UserID); err != nil {
+		if _, err := store.Followers.Follow(ctx, follower.FollowerID, follower.UserID); err != nil {
 			_ = tx.Rollback()
 			log.Println("Error to seed the followers")
 			return
@@ -94,7 +93,7 @@ func generatePosts(num int, users []*store.Users) []*store.Posts {
 		tags := make([]string, 3)
 
 		for j := 0; j < len(tags); j++ {
-			tags[j] = fmt.Sprintf("#%s", gofakeit.BuzzWord())
+			tags[j] = gofakeit.BuzzWord()
 		}
 
 		posts[i] = &store.Posts{
@@ -8,6 +8,7 @@ const (
 	FromName string = "ConnectApp"
 	maxAttempts int = 5
 	UserTemp = "user_invitation.tmpl"
+	PasswordResetTemp = "password_reset.tmpl"
 )
 
 //go:embed templates/*
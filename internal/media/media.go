@@ -0,0 +1,150 @@
+// Package media validates and normalizes uploaded images.
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// ThumbnailSize bounds the width and height of generated thumbnails.
+	ThumbnailSize = 400
+
+	jpegQuality = 85
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type, expected a JPEG, PNG, GIF or WebP image")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// Image is an uploaded image re-encoded without its metadata, together with
+// its thumbnail.
+type Image struct {
+	Data        []byte
+	Thumbnail   []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// sniffed maps the content types recognized by http.DetectContentType to
+// the ones accepted for upload.
+var sniffed = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Process reads an image, trusting its content rather than any declared
+// content type, and re-encodes it. Re-encoding drops EXIF and any other
+// metadata, so JPEG images are first turned upright following their EXIF
+// orientation. JPEG images stay JPEG, everything else becomes PNG; animated
+// GIFs keep their first frame only. Images with more than maxPixels pixels
+// are rejected before being decoded.
+func Process(r io.Reader, maxPixels int) (*Image, error) {
+	br := bufio.NewReader(r)
+
+	head, err := br.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	contentType := http.DetectContentType(head)
+	if !sniffed[contentType] {
+		return nil, ErrUnsupportedType
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, br); err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	data := buf.Bytes()
+
+	src, _, err := image.Decode(&buf)
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	if contentType == "image/jpeg" {
+		src = orient(src, jpegOrientation(data))
+	}
+
+	img := &Image{
+		ContentType: "image/png",
+		Ext:         "png",
+		Width:       src.Bounds().Dx(),
+		Height:      src.Bounds().Dy(),
+	}
+	if contentType == "image/jpeg" {
+		img.ContentType = "image/jpeg"
+		img.Ext = "jpg"
+	}
+
+	if img.Data, err = img.encode(src); err != nil {
+		return nil, err
+	}
+
+	if img.Thumbnail, err = img.encode(thumbnail(src, ThumbnailSize)); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+func (img *Image) encode(m image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var err error
+	switch img.ContentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, m, &jpeg.Options{Quality: jpegQuality})
+	default:
+		err = png.Encode(&buf, m)
+	}
+
+	return buf.Bytes(), err
+}
+
+// thumbnail scales src down to fit in a size by size square, keeping its
+// aspect ratio. Images that already fit are returned as is.
+func thumbnail(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+
+	if w >= h {
+		w, h = size, max(1, h*size/w)
+	} else {
+		w, h = max(1, w*size/h), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+	return dst
+}
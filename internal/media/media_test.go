@@ -0,0 +1,162 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	return img
+}
+
+// withExif inserts an APP1 Exif segment after the SOI marker of a JPEG.
+func withExif(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	return withApp1(data, []byte("Exif\x00\x00GPS 52.37N 4.89E"))
+}
+
+// withOrientation inserts an EXIF segment with a single orientation entry.
+func withOrientation(data []byte, orientation byte) []byte {
+	payload := []byte("Exif\x00\x00MM\x00\x2A\x00\x00\x00\x08")
+	payload = append(payload, 0x00, 0x01)
+	payload = append(payload, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, orientation, 0x00, 0x00)
+	payload = append(payload, 0x00, 0x00, 0x00, 0x00)
+
+	return withApp1(data, payload)
+}
+
+func withApp1(data, payload []byte) []byte {
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("strips EXIF from JPEG", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, testImage(64, 32), nil); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := Process(bytes.NewReader(withExif(t, buf.Bytes())), 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if img.ContentType != "image/jpeg" || img.Width != 64 || img.Height != 32 {
+			t.Errorf("unexpected image %s %dx%d", img.ContentType, img.Width, img.Height)
+		}
+
+		if bytes.Contains(img.Data, []byte("Exif")) || bytes.Contains(img.Data, []byte("GPS")) {
+			t.Error("expected EXIF to be stripped")
+		}
+	})
+
+	t.Run("applies the EXIF orientation", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, testImage(64, 32), nil); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := Process(bytes.NewReader(withOrientation(buf.Bytes(), 6)), 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(img.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if img.Width != 32 || img.Height != 64 || cfg.Width != 32 || cfg.Height != 64 {
+			t.Errorf("expected a 32x64 image, got %dx%d encoded as %dx%d", img.Width, img.Height, cfg.Width, cfg.Height)
+		}
+	})
+
+	t.Run("generates a bounded thumbnail", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, testImage(ThumbnailSize*2, ThumbnailSize)); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := Process(&buf, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		thumb, err := png.DecodeConfig(bytes.NewReader(img.Thumbnail))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if thumb.Width != ThumbnailSize || thumb.Height != ThumbnailSize/2 {
+			t.Errorf("expected a %dx%d thumbnail, got %dx%d", ThumbnailSize, ThumbnailSize/2, thumb.Width, thumb.Height)
+		}
+	})
+
+	t.Run("rejects content that is not an image", func(t *testing.T) {
+		_, err := Process(strings.NewReader("<html><body>hi</body></html>"), 1<<20)
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("expected ErrUnsupportedType, got %v", err)
+		}
+	})
+
+	t.Run("rejects images over the pixel limit", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, testImage(100, 100)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Process(&buf, 100*100-1); !errors.Is(err, ErrTooManyPixels) {
+			t.Errorf("expected ErrTooManyPixels, got %v", err)
+		}
+	})
+}
+
+func TestOrient(t *testing.T) {
+	// a 2x1 image with a red left and a blue right pixel
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		red         image.Point
+	}{
+		{1, 2, 1, image.Pt(0, 0)},
+		{2, 2, 1, image.Pt(1, 0)},
+		{3, 2, 1, image.Pt(1, 0)},
+		{6, 1, 2, image.Pt(0, 0)},
+		{8, 1, 2, image.Pt(0, 1)},
+	}
+
+	for _, tc := range tests {
+		out := orient(src, tc.orientation)
+		if b := out.Bounds(); b.Dx() != tc.w || b.Dy() != tc.h {
+			t.Errorf("orientation %d: expected %dx%d, got %dx%d", tc.orientation, tc.w, tc.h, b.Dx(), b.Dy())
+			continue
+		}
+
+		if got := color.RGBAModel.Convert(out.At(tc.red.X, tc.red.Y)); got != red {
+			t.Errorf("orientation %d: expected red at %v, got %v", tc.orientation, tc.red, got)
+		}
+	}
+}
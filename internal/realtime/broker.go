@@ -0,0 +1,42 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const (
+	EventPostCreated    = "post.created"
+	EventCommentCreated = "comment.created"
+	EventReactionAdded  = "reaction.added"
+	EventNotification   = "notification"
+	EventMessageCreated = "message.created"
+	EventMessageRead    = "message.read"
+)
+
+// Event is pushed to the connected clients of its recipients. IDs increase
+// with every published event so clients can resume after a reconnect.
+type Event struct {
+	ID    int64           `json:"id"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+	Users []int64         `json:"users"`
+}
+
+func NewEvent(kind string, data any, users ...int64) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{Type: kind, Data: raw, Users: users}, nil
+}
+
+// Broker delivers published events to the subscriptions of their
+// recipients.
+type Broker interface {
+	Publish(context.Context, Event) error
+	// Subscribe starts receiving the events of a user. Events published
+	// after lastEventID that are still in the backlog are replayed first.
+	Subscribe(userID, lastEventID int64) *Subscription
+}
@@ -0,0 +1,134 @@
+package realtime
+
+import "sync"
+
+// subscriptionBuffer is the number of events a subscriber may lag behind
+// before it is disconnected; it can then resume with its last event id.
+const subscriptionBuffer = 64
+
+// Hub keeps the subscriptions of this instance and a backlog of the most
+// recent events for resumption.
+type Hub struct {
+	mu      sync.Mutex
+	subs    map[int64]map[*Subscription]struct{}
+	backlog []Event
+	next    int
+}
+
+func NewHub(backlog int) *Hub {
+	return &Hub{
+		subs:    make(map[int64]map[*Subscription]struct{}),
+		backlog: make([]Event, 0, backlog),
+	}
+}
+
+// Deliver records e in the backlog and sends it to the subscriptions of
+// its recipients.
+func (h *Hub) Deliver(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.backlog) < cap(h.backlog) {
+		h.backlog = append(h.backlog, e)
+	} else if len(h.backlog) > 0 {
+		h.backlog[h.next] = e
+		h.next = (h.next + 1) % len(h.backlog)
+	}
+
+	for _, userID := range e.Users {
+		for sub := range h.subs[userID] {
+			select {
+			case sub.events <- e:
+			default:
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+func (h *Hub) Subscribe(userID, lastEventID int64) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay []Event
+	if lastEventID > 0 {
+		replay = h.since(userID, lastEventID)
+	}
+
+	sub := &Subscription{
+		hub:    h,
+		userID: userID,
+		events: make(chan Event, len(replay)+subscriptionBuffer),
+	}
+
+	for _, e := range replay {
+		sub.events <- e
+	}
+
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][sub] = struct{}{}
+
+	return sub
+}
+
+// since returns the backlogged events of a user published after id, oldest
+// first.
+func (h *Hub) since(userID, id int64) []Event {
+	var events []Event
+
+	for i := range h.backlog {
+		e := h.backlog[(h.next+i)%len(h.backlog)]
+		if e.ID <= id {
+			continue
+		}
+
+		for _, u := range e.Users {
+			if u == userID {
+				events = append(events, e)
+				break
+			}
+		}
+	}
+
+	return events
+}
+
+// remove closes sub; h.mu must be held.
+func (h *Hub) remove(sub *Subscription) {
+	subs, ok := h.subs[sub.userID]
+	if !ok {
+		return
+	}
+
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subs, sub.userID)
+	}
+
+	close(sub.events)
+}
+
+// Subscription receives the events of one user. Its channel is closed when
+// the subscription is closed or could not keep up.
+type Subscription struct {
+	hub    *Hub
+	userID int64
+	events chan Event
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
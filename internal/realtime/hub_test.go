@@ -0,0 +1,81 @@
+package realtime
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLocalBroker(t *testing.T) {
+	broker := NewLocalBroker(NewHub(10))
+	ctx := context.Background()
+
+	publish := func(users ...int64) {
+		t.Helper()
+
+		e, err := NewEvent(EventPostCreated, map[string]int{"id": 1}, users...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := broker.Publish(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("should deliver events to their recipients only", func(t *testing.T) {
+		sub := broker.Subscribe(1, 0)
+		defer sub.Close()
+
+		publish(2)
+		publish(1)
+
+		e := <-sub.Events()
+		if len(e.Users) != 1 || e.Users[0] != 1 {
+			t.Errorf("expected an event for user 1, got %+v", e)
+		}
+
+		if len(sub.Events()) != 0 {
+			t.Errorf("expected no other event, got %d", len(sub.Events()))
+		}
+	})
+
+	t.Run("should replay events after the last event id", func(t *testing.T) {
+		first := broker.Subscribe(3, 0)
+		publish(3)
+		seen := <-first.Events()
+		first.Close()
+
+		publish(3)
+		publish(4)
+		publish(3)
+
+		sub := broker.Subscribe(3, seen.ID)
+		defer sub.Close()
+
+		if got := len(sub.Events()); got != 2 {
+			t.Fatalf("expected 2 replayed events, got %d", got)
+		}
+
+		if e := <-sub.Events(); e.ID <= seen.ID {
+			t.Errorf("expected an event after %d, got %d", seen.ID, e.ID)
+		}
+	})
+
+	t.Run("should disconnect subscribers that fall behind", func(t *testing.T) {
+		sub := broker.Subscribe(5, 0)
+		defer sub.Close()
+
+		for i := 0; i <= subscriptionBuffer; i++ {
+			publish(5)
+		}
+
+		count := 0
+		for range sub.Events() {
+			count++
+		}
+
+		if count != subscriptionBuffer {
+			t.Errorf("expected %d buffered events before disconnecting, got %d", subscriptionBuffer, count)
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package realtime
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// LocalBroker delivers events within a single API instance.
+type LocalBroker struct {
+	hub *Hub
+	seq atomic.Int64
+}
+
+func NewLocalBroker(hub *Hub) *LocalBroker {
+	return &LocalBroker{hub: hub}
+}
+
+func (b *LocalBroker) Publish(ctx context.Context, e Event) error {
+	e.ID = b.seq.Add(1)
+	b.hub.Deliver(e)
+
+	return nil
+}
+
+func (b *LocalBroker) Subscribe(userID, lastEventID int64) *Subscription {
+	return b.hub.Subscribe(userID, lastEventID)
+}
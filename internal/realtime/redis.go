@@ -0,0 +1,74 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	eventsChannel = "events"
+	eventSeqKey   = "events-seq"
+)
+
+// RedisBroker fans events out to every API instance through Redis pub/sub.
+// Event ids come from a shared counter so that a client can resume on any
+// instance.
+type RedisBroker struct {
+	rdb *redis.Client
+	hub *Hub
+}
+
+func NewRedisBroker(rdb *redis.Client, hub *Hub) *RedisBroker {
+	return &RedisBroker{rdb: rdb, hub: hub}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, e Event) error {
+	id, err := b.rdb.Incr(ctx, eventSeqKey).Result()
+	if err != nil {
+		return err
+	}
+	e.ID = id
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return b.rdb.Publish(ctx, eventsChannel, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(userID, lastEventID int64) *Subscription {
+	return b.hub.Subscribe(userID, lastEventID)
+}
+
+// Run delivers the events published by all instances to the local
+// subscriptions until ctx is done.
+func (b *RedisBroker) Run(ctx context.Context) error {
+	pubsub := b.rdb.Subscribe(ctx, eventsChannel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+
+			var e Event
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				continue
+			}
+
+			b.hub.Deliver(e)
+		}
+	}
+}
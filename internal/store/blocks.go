@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ErrBlocked is returned when an action is refused because one of the
+// users involved blocked the other.
+var ErrBlocked = errors.New("user is blocked")
+
+type BlocksStore struct {
+	db *sql.DB
+}
+
+// Block blocks blockedID on behalf of blockerID and removes the follow
+// edges and follow requests between them in both directions.
+func (s *BlocksStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		query = `
+			DELETE FROM followers
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM follow_requests
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`
+		_, err := tx.ExecContext(ctx, query, blockerID, blockedID)
+
+		return err
+	})
+}
+
+func (s *BlocksStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+
+	return err
+}
+
+// Mute hides the content of mutedID from muterID without them knowing;
+// follow edges are kept.
+func (s *BlocksStore) Mute(ctx context.Context, muterID, mutedID int64) error {
+	query := `INSERT INTO user_mutes (muter_id, muted_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func (s *BlocksStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	query := `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+
+	return err
+}
+
+// IsBlocked reports whether either user blocked the other.
+func (s *BlocksStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `SELECT ` + blockedBetween("$1", "$2")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var blocked bool
+	err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked)
+
+	return blocked, err
+}
+
+// blockedBetween is a condition on two user id expressions that holds when
+// either user blocked the other.
+func blockedBetween(a, b string) string {
+	return `EXISTS (
+			SELECT 1 FROM user_blocks ub
+			WHERE (ub.blocker_id = ` + a + ` AND ub.blocked_id = ` + b + `)
+			OR (ub.blocker_id = ` + b + ` AND ub.blocked_id = ` + a + `)
+		)`
+}
+
+// hiddenFrom is a condition that holds when the content of author must be
+// hidden from viewer: either blocked the other or viewer muted author.
+func hiddenFrom(viewer, author string) string {
+	return `(` + blockedBetween(viewer, author) + ` OR EXISTS (
+			SELECT 1 FROM user_mutes um WHERE um.muter_id = ` + viewer + ` AND um.muted_id = ` + author + `
+		))`
+}
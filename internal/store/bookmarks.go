@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// BookmarkCollection is a named, private group of bookmarks.
+type BookmarkCollection struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+	Count     int    `json:"count"`
+}
+
+type BookmarkedPost struct {
+	PostswithMetadata
+	CollectionID *int64 `json:"collection_id"`
+	BookmarkedAt string `json:"bookmarked_at"`
+}
+
+type BookmarksStore struct {
+	db *sql.DB
+}
+
+// Save bookmarks a post, or moves an existing bookmark to collectionID.
+// It returns ErrNotFound when the collection does not belong to the user.
+func (s *BookmarksStore) Save(ctx context.Context, userID, postID int64, collectionID *int64) error {
+	query := `
+		INSERT INTO bookmarks (user_id, post_id, collection_id)
+		SELECT $1, $2, $3
+		WHERE $3::bigint IS NULL OR EXISTS (
+			SELECT 1 FROM bookmark_collections WHERE id = $3 AND user_id = $1
+		)
+		ON CONFLICT (user_id, post_id) DO UPDATE SET collection_id = EXCLUDED.collection_id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID, collectionID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Remove deletes a bookmark; removing a missing bookmark is a no-op.
+func (s *BookmarksStore) Remove(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+
+	return err
+}
+
+// List returns the bookmarked posts of a user, most recently bookmarked
+// first, optionally restricted to one collection. Posts of private accounts
+// the user no longer follows are left out.
+func (s *BookmarksStore) List(ctx context.Context, userID int64, collectionID *int64, pg PaginatedQuery) ([]BookmarkedPost, error) {
+	query := `SELECT ` + feedColumns + `, b.collection_id, b.created_at
+		FROM bookmarks b
+		JOIN posts e ON e.id = b.post_id` + feedJoins + `
+		WHERE b.user_id = $1 AND
+			($2::bigint IS NULL OR b.collection_id = $2) AND
+			($3::timestamptz IS NULL OR (b.created_at, b.post_id) < ($3::timestamptz, $4)) AND
+			` + postVisibleTo("$1", "p") + `
+		ORDER BY b.created_at DESC, b.post_id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cursorAt, cursorID := cursorArgs(pg.Cursor)
+	rows, err := s.db.QueryContext(ctx, query, userID, collectionID, cursorAt, cursorID, pg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []BookmarkedPost{}
+	for rows.Next() {
+		var b BookmarkedPost
+		if err := scanFeedPost(rows, &b.PostswithMetadata, &b.CollectionID, &b.BookmarkedAt); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+
+	return bookmarks, rows.Err()
+}
+
+func (s *BookmarksStore) CreateCollection(ctx context.Context, collection *BookmarkCollection) error {
+	query := `
+		INSERT INTO bookmark_collections (user_id, name)
+		VALUES ($1, $2) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, collection.UserID, collection.Name).Scan(&collection.ID, &collection.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *BookmarksStore) GetCollections(ctx context.Context, userID int64) ([]BookmarkCollection, error) {
+	query := `
+		SELECT bc.id, bc.user_id, bc.name, bc.created_at,
+			(SELECT COUNT(*) FROM bookmarks b WHERE b.collection_id = bc.id)
+		FROM bookmark_collections bc
+		WHERE bc.user_id = $1
+		ORDER BY bc.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []BookmarkCollection{}
+	for rows.Next() {
+		var c BookmarkCollection
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.CreatedAt, &c.Count); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	return collections, rows.Err()
+}
+
+// DeleteCollection deletes a collection of the user; its bookmarks are kept
+// outside of any collection.
+func (s *BookmarksStore) DeleteCollection(ctx context.Context, userID, collectionID int64) error {
+	query := `DELETE FROM bookmark_collections WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, collectionID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
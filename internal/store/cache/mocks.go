@@ -3,11 +3,14 @@ package cache
 import (
 	"context"
 	"go-project/internal/store"
+	"time"
 )
 
 func NewMockStore() Storage {
 	return Storage{
 		Users: &MockUserStore{},
+		Tokens: &MockTokensStore{},
+		Timelines: &MockTimelinesStore{},
 	}
 }
 
@@ -22,6 +25,34 @@ func (m *MockUserStore) Set(ctx context.Context, user *store.Users) error {
 	return nil
 }
 
-// func (m *MockUserStore) Delete(ctx context.Context, userID int64) {
-// 	return	
-// }
\ No newline at end of file
+func (m *MockUserStore) Delete(ctx context.Context, userID int64) error {
+	return nil
+}
+
+type MockTokensStore struct {}
+
+func (m *MockTokensStore) Deny(ctx context.Context, jti string, exp time.Time) error {
+	return nil
+}
+
+func (m *MockTokensStore) IsDenied(ctx context.Context, jti string) (bool, error) {
+	return false, nil
+}
+
+type MockTimelinesStore struct {}
+
+func (m *MockTimelinesStore) Push(ctx context.Context, userIDs []int64, entries ...store.Cursor) error {
+	return nil
+}
+
+func (m *MockTimelinesStore) Get(ctx context.Context, userID int64, before *store.Cursor, limit int) ([]int64, bool, error) {
+	return nil, false, nil
+}
+
+func (m *MockTimelinesStore) Remove(ctx context.Context, userIDs []int64, postID int64) error {
+	return nil
+}
+
+func (m *MockTimelinesStore) Delete(ctx context.Context, userID int64) error {
+	return nil
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"go-project/internal/store"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -11,13 +12,26 @@ type Storage struct {
 	Users interface {
 		Get(context.Context, int64)(*store.Users, error)
 		Set(context.Context, *store.Users)error
+		Delete(context.Context, int64) error
 	
 	}
+	Tokens interface {
+		Deny(context.Context, string, time.Time) error
+		IsDenied(context.Context, string) (bool, error)
+	}
+	Timelines interface {
+		Push(context.Context, []int64, ...store.Cursor) error
+		Get(context.Context, int64, *store.Cursor, int) ([]int64, bool, error)
+		Remove(context.Context, []int64, int64) error
+		Delete(context.Context, int64) error
+	}
 }
 
 
 func NewRedisStorage(rdb *redis.Client) Storage{
 	return Storage{
 		Users: &UsersStore{rdb: rdb},
+		Tokens: &TokensStore{rdb: rdb},
+		Timelines: &TimelinesStore{rdb: rdb},
 	}
 }
\ No newline at end of file
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go-project/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// TimelineMaxLen caps the number of post ids kept per timeline, older
+	// pages are served from Postgres.
+	TimelineMaxLen = 800
+	// TimelineExpTime lets the timelines of inactive users expire.
+	TimelineExpTime = time.Hour * 72
+)
+
+// TimelinesStore keeps a sorted set of feed entries per user, scored by
+// their creation time so that the newest entries come first, in the same
+// (created_at, id) order as the feed read from Postgres. Members are zero
+// padded ids, which orders entries created within the same second by id. A
+// timeline always holds the most recent part of the user's feed: entries
+// are only ever added at the head, and anything that would leave a gap
+// invalidates the whole timeline.
+type TimelinesStore struct {
+	rdb *redis.Client
+}
+
+// timelineKey names the timeline of a user; the version changed when
+// entries stopped being scored by id.
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline-v2-%d", userID)
+}
+
+func timelineMember(id int64) string {
+	return fmt.Sprintf("%020d", id)
+}
+
+// timelineScore is the creation time of an entry in seconds, the precision
+// of created_at.
+func timelineScore(c store.Cursor) (float64, error) {
+	t, err := time.Parse(time.RFC3339Nano, c.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(t.Unix()), nil
+}
+
+// Push adds feed entries, posts or reposts, to the timelines of the given
+// users.
+func (s *TimelinesStore) Push(ctx context.Context, userIDs []int64, entries ...store.Cursor) error {
+	if len(userIDs) == 0 || len(entries) == 0 {
+		return nil
+	}
+
+	members := make([]*redis.Z, len(entries))
+	for i, entry := range entries {
+		score, err := timelineScore(entry)
+		if err != nil {
+			return err
+		}
+		members[i] = &redis.Z{Score: score, Member: timelineMember(entry.ID)}
+	}
+
+	pipe := s.rdb.Pipeline()
+	for _, userID := range userIDs {
+		key := timelineKey(userID)
+		pipe.ZAdd(ctx, key, members...)
+		pipe.ZRemRangeByRank(ctx, key, 0, -TimelineMaxLen-1)
+		pipe.Expire(ctx, key, TimelineExpTime)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Get returns the ids of up to limit entries that come after before in the
+// newest first order, or of the newest ones when before is nil. The boolean
+// is false when the user has no timeline.
+func (s *TimelinesStore) Get(ctx context.Context, userID int64, before *store.Cursor, limit int) ([]int64, bool, error) {
+	key := timelineKey(userID)
+
+	exists, err := s.rdb.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if exists == 0 {
+		return nil, false, nil
+	}
+
+	max := "+inf"
+	count := int64(limit)
+	var score float64
+	if before != nil {
+		if score, err = timelineScore(*before); err != nil {
+			return nil, false, err
+		}
+
+		// entries of the cursor's second are read as well and the ones
+		// not older than the cursor are skipped below
+		max = strconv.FormatFloat(score, 'f', -1, 64)
+		same, err := s.rdb.ZCount(ctx, key, max, max).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		count += same
+	}
+
+	members, err := s.rdb.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Max:   max,
+		Min:   "-inf",
+		Count: count,
+	}).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	ids := make([]int64, 0, limit)
+	for _, member := range members {
+		id, err := strconv.ParseInt(member.Member.(string), 10, 64)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if before != nil && member.Score == score && id >= before.ID {
+			continue
+		}
+
+		if len(ids) == limit {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, true, nil
+}
+
+// Remove drops an entry from the timelines of the given users.
+func (s *TimelinesStore) Remove(ctx context.Context, userIDs []int64, postID int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	for _, userID := range userIDs {
+		pipe.ZRem(ctx, timelineKey(userID), timelineMember(postID))
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Delete drops the timeline of a user, it is rebuilt on the next read.
+func (s *TimelinesStore) Delete(ctx context.Context, userID int64) error {
+	return s.rdb.Del(ctx, timelineKey(userID)).Err()
+}
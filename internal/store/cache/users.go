@@ -54,3 +54,7 @@ func(s *UsersStore) Set(ctx context.Context, users *store.Users)error{
 
 	return s.rdb.SetEX(ctx, cacheKey, json, UserExpTime).Err()
 }
+
+func (s *UsersStore) Delete(ctx context.Context, userID int64) error {
+	return s.rdb.Del(ctx, fmt.Sprintf("user-%d", userID)).Err()
+}
@@ -3,49 +3,156 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
 )
 
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int    `json:"post_id"`
-	UserID    int    `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      Users  `json:"users"`
+	ID          int64          `json:"id"`
+	PostID      int            `json:"post_id"`
+	UserID      int            `json:"user_id"`
+	ParentID    *int64         `json:"parent_id"`
+	Content     string         `json:"content"`
+	CreatedAt   string         `json:"created_at"`
+	UpdatedAt   string         `json:"updated_at"`
+	User        Users          `json:"users"`
+	Reactions   map[string]int `json:"reactions"`
+	ReactedByMe []string       `json:"reacted_by_me"`
+	Mentions    []Mention      `json:"mentions"`
+	Replies     []Comment      `json:"replies"`
 }
 
 type CommentsStore struct {
 	db *sql.DB
 }
 
+// Create inserts a comment and links the users in comment.Mentions.
 func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
-	query := `INSERT INTO comments (post_id, user_id, content)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at`
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO comments (post_id, user_id, content, parent_id)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at, updated_at`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, comment.PostID, comment.UserID, comment.Content, comment.ParentID).Scan(&comment.ID, &comment.CreatedAt, &comment.UpdatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		comment.Mentions, err = linkMentions(ctx, tx, "comment_id", comment.ID, int64(comment.UserID), comment.Mentions)
+
+		return err
+	})
+}
+
+func (s *CommentsStore) GetbyID(ctx context.Context, commentID int64) (*Comment, error) {
+	query := `
+		SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.created_at, c.updated_at,` + commentMentionsColumn + `
+		FROM comments c
+		WHERE c.id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		c        Comment
+		mentions []byte
+	)
+	err := s.db.QueryRowContext(ctx, query, commentID).Scan(&c.ID, &c.PostID, &c.UserID, &c.ParentID, &c.Content, &c.CreatedAt, &c.UpdatedAt, &mentions)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if c.Mentions, err = decodeMentions(mentions); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// UpdatebyID saves the content of a comment and relinks the users in
+// comment.Mentions.
+func (s *CommentsStore) UpdatebyID(ctx context.Context, comment *Comment) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE comments
+			SET content = $1, updated_at = NOW()
+			WHERE id = $2
+			RETURNING updated_at
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, comment.Content, comment.ID).Scan(&comment.UpdatedAt)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		comment.Mentions, err = linkMentions(ctx, tx, "comment_id", comment.ID, int64(comment.UserID), comment.Mentions)
+
+		return err
+	})
+}
+
+// DeletebyID removes a comment; replies are removed with it by the
+// parent_id foreign key.
+func (s *CommentsStore) DeletebyID(ctx context.Context, commentID int64) error {
+	query := `DELETE FROM comments WHERE id = $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, comment.PostID, comment.UserID, comment.Content).Scan(&comment.ID, &comment.CreatedAt)
+	res, err := s.db.ExecContext(ctx, query, commentID)
 
-	if err != nil{
+	if err != nil {
 		return err
 	}
 
+	rows, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
 	return nil
 }
 
-func (s *CommentsStore) GetbyPostID(ctx context.Context, postId int64) ([]Comment, error) {
+// GetbyPostID returns the comments of a post, as seen by viewerID, as a
+// tree: top level comments are returned in the slice and replies are
+// nested under their parent. Comments of users hidden from the viewer are
+// left out together with their replies.
+func (s *CommentsStore) GetbyPostID(ctx context.Context, postId, viewerID int64) ([]Comment, error) {
 	query := `
-		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.email, users.created_at, users.id FROM Comments c
-		JOIN Users on Users.id = c.user_id
-		WHERE c.post_id = $1
+		SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.created_at, c.updated_at, users.username, users.email, users.created_at, users.id,` + commentReactionColumns + `,` + commentMentionsColumn + `
+		FROM Comments c
+		JOIN Users on Users.id = c.user_id AND Users.deleted_at IS NULL
+		WHERE c.post_id = $2 AND NOT ` + hiddenFrom("$1", "c.user_id") + `
 		ORDER BY c.created_at DESC;
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postId)
+	rows, err := s.db.QueryContext(ctx, query, viewerID, postId)
 
 	if err != nil {
 		return nil, err
@@ -54,15 +161,65 @@ func (s *CommentsStore) GetbyPostID(ctx context.Context, postId int64) ([]Commen
 
 	comments := []Comment{}
 	for rows.Next() {
-		var c Comment
+		var (
+			c         Comment
+			reactions []byte
+			mentions  []byte
+		)
 		c.User = Users{}
-		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.User.Username, &c.User.Email, &c.User.CreatedAt,  &c.User.ID)
+		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.ParentID, &c.Content, &c.CreatedAt, &c.UpdatedAt, &c.User.Username, &c.User.Email, &c.User.CreatedAt, &c.User.ID, &reactions, pq.Array(&c.ReactedByMe), &mentions)
+
+		if err != nil {
+			return nil, err
+		}
 
+		c.Reactions, err = decodeReactionCounts(reactions)
 		if err != nil {
 			return nil, err
 		}
+		c.ReactedByMe = nonNil(c.ReactedByMe)
+
+		if c.Mentions, err = decodeMentions(mentions); err != nil {
+			return nil, err
+		}
+
 		comments = append(comments, c)
 	}
 
-	return comments, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buildCommentTree(comments), nil
+}
+
+func buildCommentTree(comments []Comment) []Comment {
+	roots := []Comment{}
+	replies := make(map[int64][]Comment)
+
+	for _, c := range comments {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+			continue
+		}
+		replies[*c.ParentID] = append(replies[*c.ParentID], c)
+	}
+
+	var attach func(c *Comment)
+	attach = func(c *Comment) {
+		c.Replies = replies[c.ID]
+		if c.Replies == nil {
+			c.Replies = []Comment{}
+		}
+
+		for i := range c.Replies {
+			attach(&c.Replies[i])
+		}
+	}
+
+	for i := range roots {
+		attach(&roots[i])
+	}
+
+	return roots
 }
@@ -0,0 +1,437 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const (
+	MemberAccepted = "accepted"
+	MemberPending  = "pending"
+)
+
+// ErrNotAccepted is returned when a member who has not accepted a message
+// request acts on the conversation.
+var ErrNotAccepted = errors.New("message request has not been accepted")
+
+// Conversation is a direct message thread between two users, or a small
+// group. Status, Muted and UnreadCount describe the viewing member.
+type Conversation struct {
+	ID          int64                `json:"id"`
+	CreatedBy   *int64               `json:"created_by"`
+	IsGroup     bool                 `json:"is_group"`
+	Title       *string              `json:"title"`
+	CreatedAt   string               `json:"created_at"`
+	UpdatedAt   string               `json:"updated_at"`
+	Status      string               `json:"status"`
+	Muted       bool                 `json:"muted"`
+	UnreadCount int                  `json:"unread_count"`
+	LastMessage *Message             `json:"last_message"`
+	Members     []ConversationMember `json:"members"`
+}
+
+// ConversationMember doubles as a read receipt: LastReadMessageID is the
+// newest message the member has seen.
+type ConversationMember struct {
+	UserSummary
+	Status            string `json:"status"`
+	LastReadMessageID *int64 `json:"last_read_message_id"`
+}
+
+type Message struct {
+	ID             int64  `json:"id"`
+	ConversationID int64  `json:"conversation_id"`
+	SenderID       *int64 `json:"sender_id"`
+	Content        string `json:"content"`
+	CreatedAt      string `json:"created_at"`
+}
+
+// MemberIDs returns the ids of the members who accepted the conversation.
+func (c *Conversation) MemberIDs() []int64 {
+	ids := make([]int64, 0, len(c.Members))
+	for _, m := range c.Members {
+		if m.Status == MemberAccepted {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+type ConversationsStore struct {
+	db *sql.DB
+}
+
+// Create starts a conversation between its creator and memberIDs. Members
+// who follow the creator and are followed back join right away, the others
+// receive a message request. It returns ErrNotFound when a member does not
+// exist and ErrBlocked when the creator blocked or was blocked by a member.
+func (s *ConversationsStore) Create(ctx context.Context, c *Conversation, memberIDs []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var blocked bool
+		query := `SELECT EXISTS (SELECT 1 FROM unnest($2::bigint[]) AS m(id) WHERE ` + blockedBetween("$1", "m.id") + `)`
+		if err := tx.QueryRowContext(ctx, query, c.CreatedBy, pq.Array(memberIDs)).Scan(&blocked); err != nil {
+			return err
+		}
+
+		if blocked {
+			return ErrBlocked
+		}
+
+		query = `
+			INSERT INTO conversations (created_by, is_group, title) VALUES ($1, $2, $3)
+			RETURNING id, created_at, updated_at
+		`
+		err := tx.QueryRowContext(ctx, query, c.CreatedBy, c.IsGroup, c.Title).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		query = `INSERT INTO conversation_members (conversation_id, user_id) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, c.ID, c.CreatedBy); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO conversation_members (conversation_id, user_id, status)
+			SELECT $1, u.id,
+				CASE WHEN EXISTS (
+					SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $2
+				) AND EXISTS (
+					SELECT 1 FROM followers f WHERE f.user_id = $2 AND f.follower_id = u.id
+				) THEN 'accepted' ELSE 'pending' END
+			FROM users u
+			WHERE u.id = ANY($3) AND u.id <> $2 AND u.deleted_at IS NULL
+		`
+		res, err := tx.ExecContext(ctx, query, c.ID, c.CreatedBy, pq.Array(memberIDs))
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if int(rows) != len(memberIDs) {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// FindDirect returns the id of the one to one conversation between two
+// users.
+func (s *ConversationsStore) FindDirect(ctx context.Context, userID, otherID int64) (int64, error) {
+	query := `
+		SELECT c.id
+		FROM conversations c
+		JOIN conversation_members a ON a.conversation_id = c.id AND a.user_id = $1
+		JOIN conversation_members b ON b.conversation_id = c.id AND b.user_id = $2
+		WHERE NOT c.is_group
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// conversationColumns is the projection of conversations as seen by the
+// member me, whose id is bound to $1.
+const conversationColumns = `
+		c.id, c.created_by, c.is_group, c.title, c.created_at, c.updated_at, me.status, me.muted,
+		(
+			SELECT COUNT(*) FROM messages m
+			WHERE m.conversation_id = c.id AND m.id > COALESCE(me.last_read_message_id, 0) AND m.sender_id IS DISTINCT FROM me.user_id
+		) AS unread_count,
+		(
+			SELECT json_build_object('id', m.id, 'conversation_id', m.conversation_id, 'sender_id', m.sender_id, 'content', m.content, 'created_at', m.created_at)
+			FROM messages m WHERE m.conversation_id = c.id
+			ORDER BY m.id DESC LIMIT 1
+		) AS last_message,
+		(
+			SELECT json_agg(json_build_object('id', u.id, 'username', u.username, 'status', cm.status, 'last_read_message_id', cm.last_read_message_id) ORDER BY cm.joined_at, u.id)
+			FROM conversation_members cm JOIN users u ON u.id = cm.user_id
+			WHERE cm.conversation_id = c.id
+		) AS members`
+
+// GetByID returns a conversation as seen by viewerID, or ErrNotFound when
+// the viewer is not a member.
+func (s *ConversationsStore) GetByID(ctx context.Context, id, viewerID int64) (*Conversation, error) {
+	query := `SELECT ` + conversationColumns + `
+		FROM conversations c
+		JOIN conversation_members me ON me.conversation_id = c.id AND me.user_id = $1
+		WHERE c.id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	conversations, err := scanConversations(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conversations) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &conversations[0], nil
+}
+
+// List returns the conversations of a user with the given membership
+// status, most recently active first.
+func (s *ConversationsStore) List(ctx context.Context, userID int64, status string, pg PaginatedQuery) ([]Conversation, error) {
+	query := `SELECT ` + conversationColumns + `
+		FROM conversations c
+		JOIN conversation_members me ON me.conversation_id = c.id AND me.user_id = $1
+		WHERE me.status = $2 AND
+			($3::timestamptz IS NULL OR (c.updated_at, c.id) < ($3::timestamptz, $4))
+		ORDER BY c.updated_at DESC, c.id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cursorAt, cursorID := cursorArgs(pg.Cursor)
+	rows, err := s.db.QueryContext(ctx, query, userID, status, cursorAt, cursorID, pg.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanConversations(rows)
+}
+
+func scanConversations(rows *sql.Rows) ([]Conversation, error) {
+	conversations := []Conversation{}
+
+	for rows.Next() {
+		var (
+			c           Conversation
+			lastMessage []byte
+			members     []byte
+		)
+
+		err := rows.Scan(&c.ID, &c.CreatedBy, &c.IsGroup, &c.Title, &c.CreatedAt, &c.UpdatedAt, &c.Status, &c.Muted, &c.UnreadCount, &lastMessage, &members)
+		if err != nil {
+			return nil, err
+		}
+
+		if lastMessage != nil {
+			if err := json.Unmarshal(lastMessage, &c.LastMessage); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := json.Unmarshal(members, &c.Members); err != nil {
+			return nil, err
+		}
+
+		conversations = append(conversations, c)
+	}
+
+	return conversations, rows.Err()
+}
+
+// CountUnread returns the number of conversations of a user with unread
+// messages, leaving muted conversations out.
+func (s *ConversationsStore) CountUnread(ctx context.Context, userID int64) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM conversation_members me
+		WHERE me.user_id = $1 AND me.status = 'accepted' AND NOT me.muted AND EXISTS (
+			SELECT 1 FROM messages m
+			WHERE m.conversation_id = me.conversation_id AND m.id > COALESCE(me.last_read_message_id, 0) AND m.sender_id IS DISTINCT FROM me.user_id
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
+
+	return count, err
+}
+
+// SendMessage adds a message to a conversation on behalf of an accepted
+// member. The message counts as read by its sender. Messages between two
+// users who blocked one another are refused with ErrBlocked in one to one
+// conversations.
+func (s *ConversationsStore) SendMessage(ctx context.Context, m *Message) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var status string
+		var blocked bool
+		query := `
+			SELECT cm.status, NOT c.is_group AND EXISTS (
+				SELECT 1 FROM conversation_members o
+				WHERE o.conversation_id = cm.conversation_id AND o.user_id <> cm.user_id AND
+					` + blockedBetween("cm.user_id", "o.user_id") + `
+			)
+			FROM conversation_members cm
+			JOIN conversations c ON c.id = cm.conversation_id
+			WHERE cm.conversation_id = $1 AND cm.user_id = $2
+		`
+		err := tx.QueryRowContext(ctx, query, m.ConversationID, m.SenderID).Scan(&status, &blocked)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		switch {
+		case blocked:
+			return ErrBlocked
+		case status != MemberAccepted:
+			return ErrNotAccepted
+		}
+
+		query = `
+			INSERT INTO messages (conversation_id, sender_id, content) VALUES ($1, $2, $3)
+			RETURNING id, created_at
+		`
+		if err := tx.QueryRowContext(ctx, query, m.ConversationID, m.SenderID, m.Content).Scan(&m.ID, &m.CreatedAt); err != nil {
+			return err
+		}
+
+		query = `UPDATE conversations SET updated_at = $2 WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, m.ConversationID, m.CreatedAt); err != nil {
+			return err
+		}
+
+		query = `UPDATE conversation_members SET last_read_message_id = $3 WHERE conversation_id = $1 AND user_id = $2`
+		_, err = tx.ExecContext(ctx, query, m.ConversationID, m.SenderID, m.ID)
+
+		return err
+	})
+}
+
+// GetMessages returns the messages of a conversation, newest first.
+func (s *ConversationsStore) GetMessages(ctx context.Context, conversationID int64, pg PaginatedQuery) ([]Message, error) {
+	query := `
+		SELECT id, conversation_id, sender_id, content, created_at
+		FROM messages
+		WHERE conversation_id = $1 AND
+			($2::timestamptz IS NULL OR (created_at, id) < ($2::timestamptz, $3))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cursorAt, cursorID := cursorArgs(pg.Cursor)
+	rows, err := s.db.QueryContext(ctx, query, conversationID, cursorAt, cursorID, pg.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.SenderID, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// MarkRead moves the read receipt of a member up to messageID; receipts
+// never move back.
+func (s *ConversationsStore) MarkRead(ctx context.Context, conversationID, userID, messageID int64) error {
+	query := `
+		UPDATE conversation_members
+		SET last_read_message_id = GREATEST(COALESCE(last_read_message_id, 0), $3)
+		WHERE conversation_id = $1 AND user_id = $2 AND
+			EXISTS (SELECT 1 FROM messages WHERE id = $3 AND conversation_id = $1)
+	`
+
+	return s.updateMember(ctx, query, conversationID, userID, messageID)
+}
+
+func (s *ConversationsStore) SetMuted(ctx context.Context, conversationID, userID int64, muted bool) error {
+	query := `UPDATE conversation_members SET muted = $3 WHERE conversation_id = $1 AND user_id = $2`
+
+	return s.updateMember(ctx, query, conversationID, userID, muted)
+}
+
+// Accept accepts a message request.
+func (s *ConversationsStore) Accept(ctx context.Context, conversationID, userID int64) error {
+	query := `
+		UPDATE conversation_members SET status = 'accepted', joined_at = NOW()
+		WHERE conversation_id = $1 AND user_id = $2 AND status = 'pending'
+	`
+
+	return s.updateMember(ctx, query, conversationID, userID)
+}
+
+// Leave removes a member from a conversation, which also declines a
+// message request.
+func (s *ConversationsStore) Leave(ctx context.Context, conversationID, userID int64) error {
+	query := `DELETE FROM conversation_members WHERE conversation_id = $1 AND user_id = $2`
+
+	return s.updateMember(ctx, query, conversationID, userID)
+}
+
+// updateMember runs a statement on the membership of userID in
+// conversationID, returning ErrNotFound when it matched no row.
+func (s *ConversationsStore) updateMember(ctx context.Context, query string, conversationID, userID int64, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, append([]any{conversationID, userID}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 
 	"github.com/lib/pq"
 )
@@ -17,32 +18,264 @@ type FollowersStore struct {
 	db *sql.DB
 }
 
-func (s *FollowersStore) Follow(ctx context.Context, followerID, userID int64) error {
-	query := `INSERT INTO followers(user_id, follower_id) VALUES ($1, $2)`
+// Connection is a user in a followers or following list, with its
+// relationship to the viewer of the list.
+type Connection struct {
+	UserSummary
+	FollowedAt   string `json:"followed_at"`
+	FollowedByMe bool   `json:"followed_by_me"`
+	FollowsMe    bool   `json:"follows_me"`
+}
+
+// FollowRequest is a pending follow of a private account.
+type FollowRequest struct {
+	UserSummary
+	CreatedAt string `json:"created_at"`
+}
+
+// Follow makes followerID follow userID, or asks to when userID is a
+// private account, in which case requested is true. It returns ErrNotFound
+// when userID does not exist, ErrBlocked when either user blocked the other
+// and ErrConflict when the follow or the request already exists.
+func (s *FollowersStore) Follow(ctx context.Context, followerID, userID int64) (requested bool, err error) {
+	err = withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var private, blocked, following bool
+		query := `
+			SELECT u.is_private, ` + blockedBetween("$1", "$2") + `,
+				EXISTS (SELECT 1 FROM followers f WHERE f.user_id = $1 AND f.follower_id = $2)
+			FROM users u WHERE u.id = $1
+		`
+		err := tx.QueryRowContext(ctx, query, userID, followerID).Scan(&private, &blocked, &following)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		switch {
+		case blocked:
+			return ErrBlocked
+		case following:
+			return ErrConflict
+		}
+
+		query = `INSERT INTO followers(user_id, follower_id) VALUES ($1, $2)`
+		if private {
+			query = `INSERT INTO follow_requests(user_id, follower_id) VALUES ($1, $2)`
+		}
+
+		if _, err := tx.ExecContext(ctx, query, userID, followerID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		requested = private
+		return nil
+	})
+
+	return requested, err
+}
+
+// Unfollow removes the follow of userID by followerID, or cancels the
+// pending request.
+func (s *FollowersStore) Unfollow(ctx context.Context, followerID, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `DELETE FROM followers WHERE user_id = $1 AND follower_id = $2`
+		if _, err := tx.ExecContext(ctx, query, userID, followerID); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM follow_requests WHERE user_id = $1 AND follower_id = $2`
+		_, err := tx.ExecContext(ctx, query, userID, followerID)
+
+		return err
+	})
+}
+
+// GetRequests returns the pending follow requests of userID, newest first.
+func (s *FollowersStore) GetRequests(ctx context.Context, userID int64, pg PaginatedQuery) ([]FollowRequest, error) {
+	query := `
+		SELECT u.id, u.username, fr.created_at
+		FROM follow_requests fr
+		JOIN users u ON u.id = fr.follower_id AND u.deleted_at IS NULL
+		WHERE fr.user_id = $1 AND
+			($2::timestamptz IS NULL OR (fr.created_at, fr.follower_id) < ($2::timestamptz, $3))
+		ORDER BY fr.created_at DESC, fr.follower_id DESC
+		LIMIT $4
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
-
+	cursorAt, cursorID := cursorArgs(pg.Cursor)
+	rows, err := s.db.QueryContext(ctx, query, userID, cursorAt, cursorID, pg.Limit)
 	if err != nil {
-		// switch err{
-		// 	case 
-		// }
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505"{
-			return ErrConflict
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+	for rows.Next() {
+		var fr FollowRequest
+		if err := rows.Scan(&fr.ID, &fr.Username, &fr.CreatedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, fr)
+	}
+
+	return requests, rows.Err()
+}
+
+// ApproveRequest turns the follow request of followerID into a follow of
+// userID.
+func (s *FollowersStore) ApproveRequest(ctx context.Context, userID, followerID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deleteRequest(ctx, tx, userID, followerID); err != nil {
+			return err
 		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `INSERT INTO followers(user_id, follower_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+		_, err := tx.ExecContext(ctx, query, userID, followerID)
+
+		return err
+	})
+}
+
+// DenyRequest drops the follow request of followerID without telling them.
+func (s *FollowersStore) DenyRequest(ctx context.Context, userID, followerID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.deleteRequest(ctx, tx, userID, followerID)
+	})
+}
+
+func (s *FollowersStore) deleteRequest(ctx context.Context, tx *sql.Tx, userID, followerID int64) error {
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND follower_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, userID, followerID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
 	}
+
 	return nil
 }
 
-func (s *FollowersStore) Unfollow(ctx context.Context, followerID, userID int64) error {
-	query := `DELETE FROM followers WHERE user_id = $1 AND follower_id = $2`
+// GetFollowerIDs returns the ids of the users following userID.
+func (s *FollowersStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `SELECT follower_id FROM followers WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetFollowers returns the users following userID, most recent first, as
+// seen by viewerID. Users blocked by or blocking the viewer are left out.
+func (s *FollowersStore) GetFollowers(ctx context.Context, userID, viewerID int64, pg PaginatedQuery) ([]Connection, error) {
+	return s.connections(ctx, "f.follower_id", "f.user_id", userID, viewerID, pg)
+}
+
+// GetFollowing returns the users userID follows, most recent first, as
+// seen by viewerID.
+func (s *FollowersStore) GetFollowing(ctx context.Context, userID, viewerID int64, pg PaginatedQuery) ([]Connection, error) {
+	return s.connections(ctx, "f.user_id", "f.follower_id", userID, viewerID, pg)
+}
+
+// connections lists the users in column listed of the follow edges whose
+// column owner is userID.
+func (s *FollowersStore) connections(ctx context.Context, listed, owner string, userID, viewerID int64, pg PaginatedQuery) ([]Connection, error) {
+	query := `
+		SELECT u.id, u.username, f.created_at,
+			EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = u.id AND vf.follower_id = $2),
+			EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = $2 AND vf.follower_id = u.id)
+		FROM followers f
+		JOIN users u ON u.id = ` + listed + ` AND u.deleted_at IS NULL
+		WHERE ` + owner + ` = $1 AND
+			NOT ` + blockedBetween("$2", "u.id") + ` AND
+			($3::timestamptz IS NULL OR (f.created_at, ` + listed + `) < ($3::timestamptz, $4))
+		ORDER BY f.created_at DESC, ` + listed + ` DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cursorAt, cursorID := cursorArgs(pg.Cursor)
+	rows, err := s.db.QueryContext(ctx, query, userID, viewerID, cursorAt, cursorID, pg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	connections := []Connection{}
+	for rows.Next() {
+		var c Connection
+		if err := rows.Scan(&c.ID, &c.Username, &c.FollowedAt, &c.FollowedByMe, &c.FollowsMe); err != nil {
+			return nil, err
+		}
+		connections = append(connections, c)
+	}
+
+	return connections, rows.Err()
+}
+
+// FollowsPopular reports whether followerID follows at least one user with
+// minFollowers followers or more.
+func (s *FollowersStore) FollowsPopular(ctx context.Context, followerID int64, minFollowers int) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM followers f
+			JOIN users u ON u.id = f.user_id
+			WHERE f.follower_id = $1 AND u.followers_count >= $2
+		)
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
+	var follows bool
+	err := s.db.QueryRowContext(ctx, query, followerID, minFollowers).Scan(&follows)
 
-	return err
+	return follows, err
 }
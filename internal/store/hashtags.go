@@ -0,0 +1,354 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxTagLength is the maximum length of a hashtag in runes.
+const MaxTagLength = 100
+
+type Hashtag struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+// TrendingTag is a hashtag used by a growing number of authors. Authors and
+// Posts count the current window, PreviousAuthors the window before it.
+type TrendingTag struct {
+	Name            string  `json:"name"`
+	Posts           int     `json:"posts"`
+	Authors         int     `json:"authors"`
+	PreviousAuthors int     `json:"previous_authors"`
+	Score           float64 `json:"score"`
+}
+
+// NormalizeTag returns the canonical form of a hashtag: without its leading
+// #, NFKC normalized and case folded. It reports false when the tag is
+// empty, too long or contains anything but letters, digits and underscores.
+func NormalizeTag(tag string) (string, bool) {
+	tag = cases.Fold().String(norm.NFKC.String(strings.TrimLeft(tag, "#")))
+
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", false
+	}
+
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r) && r != '_' {
+			return "", false
+		}
+	}
+
+	return tag, true
+}
+
+// NormalizeTags returns the distinct canonical forms of tags, in order of
+// first appearance, leaving out invalid ones.
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		if tag, ok := NormalizeTag(tag); ok && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+
+	return normalized
+}
+
+type HashtagsStore struct {
+	db *sql.DB
+}
+
+// GetPosts returns the posts tagged with tag as seen by viewerID, newest
+// first.
+func (s *HashtagsStore) GetPosts(ctx context.Context, tag string, viewerID int64, pg PaginatedQuery) ([]PostswithMetadata, error) {
+	query := `SELECT ` + feedColumns + `
+		FROM post_hashtags ph
+		JOIN hashtags h ON h.id = ph.hashtag_id
+		JOIN posts e ON e.id = ph.post_id` + feedJoins + `
+		WHERE h.name = $2 AND
+			NOT ` + hiddenFrom("$1", "p.user_id") + ` AND
+			` + postVisibleTo("$1", "p") + ` AND
+			($3::timestamptz IS NULL OR (ph.created_at, ph.post_id) < ($3::timestamptz, $4))
+		ORDER BY ph.created_at DESC, ph.post_id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cursorAt, cursorID := cursorArgs(pg.Cursor)
+	rows, err := s.db.QueryContext(ctx, query, viewerID, tag, cursorAt, cursorID, pg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFeed(rows)
+}
+
+// Trending returns the hashtags used by at least minAuthors authors within
+// the last window, ranked by how many authors used them and how much that
+// number grew compared to the window before. Only public posts of public
+// accounts are counted.
+func (s *HashtagsStore) Trending(ctx context.Context, window time.Duration, minAuthors, limit int) ([]TrendingTag, error) {
+	query := `
+		WITH recent AS (
+			SELECT ph.hashtag_id,
+				COUNT(*) FILTER (WHERE ph.created_at >= NOW() - $1 * interval '1 second') AS posts,
+				COUNT(DISTINCT p.user_id) FILTER (WHERE ph.created_at >= NOW() - $1 * interval '1 second') AS authors,
+				COUNT(DISTINCT p.user_id) FILTER (WHERE ph.created_at < NOW() - $1 * interval '1 second') AS previous_authors
+			FROM post_hashtags ph
+			JOIN posts p ON p.id = ph.post_id
+			JOIN users u ON u.id = p.user_id
+			WHERE ph.created_at >= NOW() - 2 * $1 * interval '1 second' AND
+				p.status = 'published' AND p.visibility = 'public' AND NOT u.is_private AND
+				p.deleted_at IS NULL AND u.deleted_at IS NULL
+			GROUP BY ph.hashtag_id
+		)
+		SELECT h.name, r.posts, r.authors, r.previous_authors,
+			r.authors::float8 * r.authors / (r.previous_authors + 1) AS score
+		FROM recent r
+		JOIN hashtags h ON h.id = r.hashtag_id
+		WHERE r.authors >= $2
+		ORDER BY score DESC, r.posts DESC, h.name
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, int64(window.Seconds()), minAuthors, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trending := []TrendingTag{}
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Name, &t.Posts, &t.Authors, &t.PreviousAuthors, &t.Score); err != nil {
+			return nil, err
+		}
+		trending = append(trending, t)
+	}
+
+	return trending, rows.Err()
+}
+
+// Follow makes the posts tagged with tag appear in the feed of userID. It
+// returns ErrConflict when the tag is already followed.
+func (s *HashtagsStore) Follow(ctx context.Context, userID int64, tag string) error {
+	query := `
+		WITH tag AS (
+			INSERT INTO hashtags (name) VALUES ($2)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id
+		)
+		INSERT INTO tag_follows (user_id, hashtag_id) SELECT $1, id FROM tag
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, tag)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrConflict
+	}
+
+	return err
+}
+
+func (s *HashtagsStore) Unfollow(ctx context.Context, userID int64, tag string) error {
+	query := `
+		DELETE FROM tag_follows tf
+		USING hashtags h
+		WHERE tf.hashtag_id = h.id AND tf.user_id = $1 AND h.name = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, tag)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetFollowed returns the hashtags userID follows, in alphabetical order.
+func (s *HashtagsStore) GetFollowed(ctx context.Context, userID int64) ([]Hashtag, error) {
+	query := `
+		SELECT h.id, h.name, h.created_at
+		FROM tag_follows tf
+		JOIN hashtags h ON h.id = tf.hashtag_id
+		WHERE tf.user_id = $1
+		ORDER BY h.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Hashtag{}
+	for rows.Next() {
+		var h Hashtag
+		if err := rows.Scan(&h.ID, &h.Name, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, h)
+	}
+
+	return tags, rows.Err()
+}
+
+// FollowsAny reports whether userID follows at least one hashtag.
+func (s *HashtagsStore) FollowsAny(ctx context.Context, userID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM tag_follows WHERE user_id = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var follows bool
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&follows)
+
+	return follows, err
+}
+
+// Backfill brings the tags of the existing posts to their canonical form
+// and indexes them, batchSize posts at a time. It returns the number of
+// posts processed and is safe to run again.
+func (s *HashtagsStore) Backfill(ctx context.Context, batchSize int) (int, error) {
+	var after int64
+	total := 0
+
+	for {
+		var posts []Posts
+		err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			query := `
+				SELECT id, tags, created_at FROM posts
+				WHERE id > $1 AND tags IS NOT NULL
+				ORDER BY id
+				LIMIT $2
+				FOR UPDATE
+			`
+			rows, err := tx.QueryContext(ctx, query, after, batchSize)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var post Posts
+				if err := rows.Scan(&post.ID, pq.Array(&post.Tags), &post.CreatedAt); err != nil {
+					return err
+				}
+				posts = append(posts, post)
+			}
+
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			for i := range posts {
+				posts[i].Tags = NormalizeTags(posts[i].Tags)
+
+				query = `UPDATE posts SET tags = $2 WHERE id = $1`
+				if _, err := tx.ExecContext(ctx, query, posts[i].ID, pq.Array(posts[i].Tags)); err != nil {
+					return err
+				}
+
+				if err := relinkHashtags(ctx, tx, &posts[i]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += len(posts)
+		if len(posts) < batchSize {
+			return total, nil
+		}
+
+		after = posts[len(posts)-1].ID
+	}
+}
+
+// linkHashtags records the tags of a post in the hashtag index.
+func linkHashtags(ctx context.Context, tx *sql.Tx, post *Posts) error {
+	if len(post.Tags) == 0 {
+		return nil
+	}
+
+	query := `
+		WITH tags AS (
+			INSERT INTO hashtags (name) SELECT unnest($2::varchar[])
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id
+		)
+		INSERT INTO post_hashtags (post_id, hashtag_id, created_at)
+		SELECT $1, id, $3 FROM tags
+	`
+
+	_, err := tx.ExecContext(ctx, query, post.ID, pq.Array(post.Tags), post.CreatedAt)
+
+	return err
+}
+
+// relinkHashtags replaces the tags of an updated post in the hashtag index.
+func relinkHashtags(ctx context.Context, tx *sql.Tx, post *Posts) error {
+	query := `DELETE FROM post_hashtags WHERE post_id = $1`
+	if _, err := tx.ExecContext(ctx, query, post.ID); err != nil {
+		return err
+	}
+
+	return linkHashtags(ctx, tx, post)
+}
+
+// redateHashtags moves the tags of a post to the time it was published, so
+// that a post published after it was drafted is listed and trends as new.
+func redateHashtags(ctx context.Context, tx *sql.Tx, postID int64) error {
+	query := `
+		UPDATE post_hashtags ph SET created_at = p.created_at
+		FROM posts p
+		WHERE p.id = ph.post_id AND ph.post_id = $1
+	`
+
+	_, err := tx.ExecContext(ctx, query, postID)
+
+	return err
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"golang", "golang", true},
+		{"#GoLang", "golang", true},
+		{"##go_lang", "go_lang", true},
+		{"Straße", "strasse", true},
+		{"ｇｏ", "go", true},
+		{"café", "café", true},
+		{"go-lang", "", false},
+		{"#", "", false},
+		{"", "", false},
+		{strings.Repeat("a", MaxTagLength+1), "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeTag(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeTag(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{"#Go", "go", "rust", "bad tag", "GO"})
+	want := []string{"go", "rust"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTags() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidMedia = errors.New("media does not exist or is already attached")
+
+// Media is an uploaded image. It is attached to at most one post, in which
+// it appears at Position.
+type Media struct {
+	ID           int64  `json:"id"`
+	UserID       int64  `json:"user_id"`
+	PostID       *int64 `json:"post_id"`
+	Position     int    `json:"position"`
+	Key          string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Size         int64  `json:"size"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type MediaStore struct {
+	db *sql.DB
+}
+
+// Create records an upload that is not attached to any post yet.
+func (s *MediaStore) Create(ctx context.Context, m *Media) error {
+	query := `
+		INSERT INTO media (user_id, key, thumbnail_key, url, thumbnail_url, content_type, width, height, size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, m.UserID, m.Key, m.ThumbnailKey, m.URL, m.ThumbnailURL, m.ContentType, m.Width, m.Height, m.Size).
+		Scan(&m.ID, &m.CreatedAt)
+}
+
+// attachMedia attaches the uploads listed by id in post.Media to the post,
+// in the order listed, and loads them into post.Media. Only uploads of the
+// post's author that are not attached yet qualify, otherwise
+// ErrInvalidMedia is returned.
+func attachMedia(ctx context.Context, tx *sql.Tx, post *Posts) error {
+	if len(post.Media) == 0 {
+		post.Media = []Media{}
+		return nil
+	}
+
+	ids := make([]int64, len(post.Media))
+	for i, m := range post.Media {
+		ids[i] = m.ID
+	}
+
+	query := `
+		UPDATE media SET post_id = $1, position = array_position($3::bigint[], id)
+		WHERE id = ANY($3::bigint[]) AND user_id = $2 AND post_id IS NULL
+		RETURNING ` + mediaColumns
+
+	rows, err := tx.QueryContext(ctx, query, post.ID, post.UserID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	attached := make(map[int64]Media, len(ids))
+	for rows.Next() {
+		var m Media
+		if err := rows.Scan(&m.ID, &m.UserID, &m.PostID, &m.Position, &m.URL, &m.ThumbnailURL, &m.ContentType, &m.Width, &m.Height, &m.Size, &m.CreatedAt); err != nil {
+			return err
+		}
+		attached[m.ID] = m
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(attached) != len(ids) {
+		return ErrInvalidMedia
+	}
+
+	for i, id := range ids {
+		post.Media[i] = attached[id]
+	}
+
+	return nil
+}
+
+const mediaColumns = `id, user_id, post_id, position, url, thumbnail_url, content_type, width, height, size, created_at`
+
+// postMediaColumn selects the attachments of the post aliased p as a JSON
+// array in display order.
+const postMediaColumn = `
+		(SELECT COALESCE(json_agg(json_build_object(
+			'id', m.id, 'user_id', m.user_id, 'post_id', m.post_id, 'position', m.position,
+			'url', m.url, 'thumbnail_url', m.thumbnail_url, 'content_type', m.content_type,
+			'width', m.width, 'height', m.height, 'size', m.size, 'created_at', m.created_at
+		) ORDER BY m.position), '[]') FROM media m WHERE m.post_id = p.id) AS media`
+
+func decodeMedia(data []byte) ([]Media, error) {
+	media := []Media{}
+	if err := json.Unmarshal(data, &media); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// PurgeUnattached deletes the uploads that were never attached to a post
+// within age of being uploaded, returning the blob keys of their images and
+// thumbnails.
+func (s *MediaStore) PurgeUnattached(ctx context.Context, age time.Duration) ([]string, error) {
+	query := `
+		WITH purged AS (
+			DELETE FROM media WHERE post_id IS NULL AND created_at < NOW() - $1 * interval '1 second'
+			RETURNING key, thumbnail_key
+		)
+		SELECT unnest(ARRAY[key, thumbnail_key]) FROM purged
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return queryStrings(ctx, s.db, query, int64(age.Seconds()))
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+// Mention is an @username in the content of a post or comment, linked to
+// the mentioned user. Start and End are offsets in Unicode code points and
+// cover the @ and the username.
+type Mention struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// linkMentions replaces the mentions of the post or comment whose id is
+// stored in column, post_id or comment_id, with those of mentions whose
+// username belongs to an active user that authorID did not block and was
+// not blocked by. It returns the mentions that were linked, in order, with
+// their user ids.
+func linkMentions(ctx context.Context, tx *sql.Tx, column string, id, authorID int64, mentions []Mention) ([]Mention, error) {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mentions WHERE `+column+` = $1`, id); err != nil {
+		return nil, err
+	}
+
+	linked := []Mention{}
+	if len(mentions) == 0 {
+		return linked, nil
+	}
+
+	usernames := make([]string, len(mentions))
+	for i, m := range mentions {
+		usernames[i] = m.Username
+	}
+
+	query := `
+		SELECT u.id, u.username FROM users u
+		WHERE u.username = ANY($1) AND u.is_active = true AND u.deleted_at IS NULL AND NOT ` + blockedBetween("$2", "u.id")
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(usernames), authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int64, len(mentions))
+	for rows.Next() {
+		var (
+			userID   int64
+			username string
+		)
+		if err := rows.Scan(&userID, &username); err != nil {
+			return nil, err
+		}
+		ids[username] = userID
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		userIDs      []int64
+		linkedNames  []string
+		starts, ends []int64
+	)
+	for _, m := range mentions {
+		userID, ok := ids[m.Username]
+		if !ok {
+			continue
+		}
+
+		m.UserID = userID
+		linked = append(linked, m)
+		userIDs = append(userIDs, userID)
+		linkedNames = append(linkedNames, m.Username)
+		starts = append(starts, int64(m.Start))
+		ends = append(ends, int64(m.End))
+	}
+
+	if len(linked) == 0 {
+		return linked, nil
+	}
+
+	query = `
+		INSERT INTO mentions (` + column + `, user_id, username, start_offset, end_offset)
+		SELECT $1, * FROM unnest($2::bigint[], $3::varchar[], $4::int[], $5::int[])
+	`
+	_, err = tx.ExecContext(ctx, query, id, pq.Array(userIDs), pq.Array(linkedNames), pq.Array(starts), pq.Array(ends))
+	if err != nil {
+		return nil, err
+	}
+
+	return linked, nil
+}
+
+// postMentionsColumn and commentMentionsColumn select the mentions of the
+// post aliased p and of the comment aliased c as a JSON array in order of
+// appearance. Mentions between users that blocked each other since are
+// left out.
+var (
+	postMentionsColumn = `
+		(SELECT COALESCE(json_agg(json_build_object(
+			'user_id', m.user_id, 'username', m.username, 'start', m.start_offset, 'end', m.end_offset
+		) ORDER BY m.start_offset), '[]') FROM mentions m
+		WHERE m.post_id = p.id AND NOT ` + blockedBetween("p.user_id", "m.user_id") + `) AS mentions`
+
+	commentMentionsColumn = `
+		(SELECT COALESCE(json_agg(json_build_object(
+			'user_id', m.user_id, 'username', m.username, 'start', m.start_offset, 'end', m.end_offset
+		) ORDER BY m.start_offset), '[]') FROM mentions m
+		WHERE m.comment_id = c.id AND NOT ` + blockedBetween("c.user_id", "m.user_id") + `) AS mentions`
+)
+
+func decodeMentions(data []byte) ([]Mention, error) {
+	mentions := []Mention{}
+	if err := json.Unmarshal(data, &mentions); err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
@@ -10,6 +10,8 @@ import (
 func NewMockStore() Storage {
 	return Storage{
 		Users: &MockUserStore{},
+		Tokens: &MockTokensStore{},
+		Blocks: &MockBlocksStore{},
 	}
 }
 
@@ -37,4 +39,91 @@ func (m *MockUserStore) Activation(ctx context.Context, t string) error {
 
 func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
 	return nil
-}
\ No newline at end of file
+}
+
+func (m *MockUserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ResetPassword(ctx context.Context, token string, password *Password) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockUserStore) SetPrivate(ctx context.Context, userID int64, private bool) error {
+	return nil
+}
+
+func (m *MockUserStore) GetProfile(ctx context.Context, userID, viewerID int64) (*Profile, error) {
+	return &Profile{Users: Users{ID: userID}}, nil
+}
+
+func (m *MockUserStore) UpdateProfile(ctx context.Context, user *Users, cooldown time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) SetAvatar(ctx context.Context, userID int64, key, url string) (string, error) {
+	return "", nil
+}
+
+func (m *MockUserStore) SoftDelete(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (m *MockUserStore) Restore(ctx context.Context, userID int64, window time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) Purge(ctx context.Context, retention time.Duration) ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *MockUserStore) ResolveUsername(ctx context.Context, username string) (int64, string, error) {
+	return 0, "", ErrNotFound
+}
+type MockTokensStore struct {}
+
+func (m *MockTokensStore) Create(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockTokensStore) Rotate(ctx context.Context, token, newToken string, exp time.Duration) (*RefreshToken, error) {
+	return &RefreshToken{}, nil
+}
+
+func (m *MockTokensStore) RevokeFamily(ctx context.Context, userID int64, token string) error {
+	return nil
+}
+
+func (m *MockTokensStore) Deny(ctx context.Context, jti string, exp time.Time) error {
+	return nil
+}
+
+func (m *MockTokensStore) IsDenied(ctx context.Context, jti string) (bool, error) {
+	return false, nil
+}
+
+func (m *MockTokensStore) PurgeExpired(ctx context.Context) error {
+	return nil
+}
+
+type MockBlocksStore struct {}
+
+func (m *MockBlocksStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	return nil
+}
+
+func (m *MockBlocksStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	return nil
+}
+
+func (m *MockBlocksStore) Mute(ctx context.Context, muterID, mutedID int64) error {
+	return nil
+}
+
+func (m *MockBlocksStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	return nil
+}
+
+func (m *MockBlocksStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const (
+	NotificationFollow        = "follow"
+	NotificationFollowRequest = "follow_request"
+	NotificationComment       = "comment"
+	NotificationReply         = "reply"
+	NotificationMention       = "mention"
+	NotificationReaction      = "reaction"
+	NotificationRepost        = "repost"
+)
+
+var NotificationKinds = []string{
+	NotificationFollow,
+	NotificationFollowRequest,
+	NotificationComment,
+	NotificationReply,
+	NotificationMention,
+	NotificationReaction,
+	NotificationRepost,
+}
+
+func IsNotificationKind(kind string) bool {
+	for _, k := range NotificationKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// UserSummary is the public part of a user embedded in other resources.
+type UserSummary struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// Notification tells UserID that ActorID did something of the given kind,
+// to the post or comment it references.
+type Notification struct {
+	ID        int64   `json:"id"`
+	UserID    int64   `json:"user_id"`
+	ActorID   int64   `json:"actor_id"`
+	Kind      string  `json:"kind"`
+	PostID    *int64  `json:"post_id"`
+	CommentID *int64  `json:"comment_id"`
+	ReadAt    *string `json:"read_at"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// NotificationGroup folds similar notifications, the same kind on the same
+// post or comment, into one entry such as "5 people liked your post".
+type NotificationGroup struct {
+	Kind       string `json:"kind"`
+	PostID     *int64 `json:"post_id"`
+	CommentID  *int64 `json:"comment_id"`
+	Unread     bool   `json:"unread"`
+	ActorCount int    `json:"actor_count"`
+	// Actors are the most recent actors of the group, at most three.
+	Actors    []UserSummary `json:"actors"`
+	IDs       []int64       `json:"ids"`
+	LatestID  int64         `json:"latest_id"`
+	CreatedAt string        `json:"created_at"`
+}
+
+type NotificationsStore struct {
+	db *sql.DB
+}
+
+// Create notifies n.UserID unless the user notifies themselves, disabled
+// the kind, blocked or was blocked by the actor or was already notified of
+// the same follow, reaction or repost.
+// n.ID is only set when the notification was created.
+func (s *NotificationsStore) Create(ctx context.Context, n *Notification) error {
+	query := `
+		INSERT INTO notifications (user_id, actor_id, kind, post_id, comment_id)
+		SELECT $1, $2, $3, $4, $5
+		WHERE $1 <> $2 AND ` + notificationEnabled + ` AND NOT ` + blockedBetween("$1", "$2") + `
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, n.UserID, n.ActorID, n.Kind, n.PostID, n.CommentID).Scan(&n.ID, &n.CreatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return nil
+}
+
+// notificationEnabled checks the preference of user $1 for kind $3.
+const notificationEnabled = `NOT EXISTS (
+			SELECT 1 FROM notification_preferences np
+			WHERE np.user_id = $1 AND np.kind = $3 AND NOT np.enabled
+		)`
+
+// notificationLive holds for notifications n whose actor and post were not
+// deleted.
+const notificationLive = `NOT EXISTS (
+				SELECT 1 FROM users du WHERE du.id = n.actor_id AND du.deleted_at IS NOT NULL
+			) AND NOT EXISTS (
+				SELECT 1 FROM posts dp WHERE dp.id = n.post_id AND dp.deleted_at IS NOT NULL
+			)`
+
+// List returns the notifications of a user grouped by kind and target, the
+// most recent group first. Read and unread notifications are grouped apart.
+func (s *NotificationsStore) List(ctx context.Context, userID int64, unreadOnly bool, pg PaginatedQuery) ([]NotificationGroup, error) {
+	query := `
+		WITH groups AS (
+			SELECT n.kind, n.post_id, n.comment_id, n.read_at IS NULL AS unread,
+				COUNT(DISTINCT n.actor_id) AS actor_count,
+				(array_agg(n.actor_id ORDER BY n.id DESC))[1:3] AS actor_ids,
+				array_agg(n.id ORDER BY n.id DESC) AS ids,
+				MAX(n.id) AS latest_id,
+				MAX(n.created_at) AS created_at
+			FROM notifications n
+			WHERE n.user_id = $1 AND (NOT $2 OR n.read_at IS NULL) AND ` + notificationLive + `
+			GROUP BY n.kind, n.post_id, n.comment_id, n.read_at IS NULL
+		)
+		SELECT g.kind, g.post_id, g.comment_id, g.unread, g.actor_count, g.ids, g.latest_id, g.created_at,
+			COALESCE((
+				SELECT json_agg(json_build_object('id', u.id, 'username', u.username) ORDER BY array_position(g.actor_ids, u.id))
+				FROM users u WHERE u.id = ANY(g.actor_ids)
+			), '[]')
+		FROM groups g
+		WHERE ($3::timestamptz IS NULL OR (g.created_at, g.latest_id) < ($3::timestamptz, $4))
+		ORDER BY g.created_at DESC, g.latest_id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cursorAt, cursorID := cursorArgs(pg.Cursor)
+	rows, err := s.db.QueryContext(ctx, query, userID, unreadOnly, cursorAt, cursorID, pg.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	groups := []NotificationGroup{}
+	for rows.Next() {
+		var (
+			g      NotificationGroup
+			actors []byte
+		)
+
+		err := rows.Scan(&g.Kind, &g.PostID, &g.CommentID, &g.Unread, &g.ActorCount, pq.Array(&g.IDs), &g.LatestID, &g.CreatedAt, &actors)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(actors, &g.Actors); err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+func (s *NotificationsStore) CountUnread(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM notifications n WHERE n.user_id = $1 AND n.read_at IS NULL AND ` + notificationLive
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
+
+	return count, err
+}
+
+// MarkRead marks the given notifications of a user as read, or all of them
+// when ids is empty. Ids of other users' notifications are ignored.
+func (s *NotificationsStore) MarkRead(ctx context.Context, userID int64, ids []int64) error {
+	query := `
+		UPDATE notifications SET read_at = NOW()
+		WHERE user_id = $1 AND read_at IS NULL AND (cardinality($2::bigint[]) = 0 OR id = ANY($2))
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, pq.Array(ids))
+
+	return err
+}
+
+// GetPreferences returns whether each notification kind is enabled for a
+// user; kinds are enabled unless the user turned them off.
+func (s *NotificationsStore) GetPreferences(ctx context.Context, userID int64) (map[string]bool, error) {
+	query := `SELECT kind, enabled FROM notification_preferences WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	prefs := make(map[string]bool, len(NotificationKinds))
+	for _, kind := range NotificationKinds {
+		prefs[kind] = true
+	}
+
+	for rows.Next() {
+		var (
+			kind    string
+			enabled bool
+		)
+
+		if err := rows.Scan(&kind, &enabled); err != nil {
+			return nil, err
+		}
+
+		prefs[kind] = enabled
+	}
+
+	return prefs, rows.Err()
+}
+
+func (s *NotificationsStore) UpdatePreferences(ctx context.Context, userID int64, prefs map[string]bool) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO notification_preferences (user_id, kind, enabled) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, kind) DO UPDATE SET enabled = EXCLUDED.enabled
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		for kind, enabled := range prefs {
+			if _, err := tx.ExecContext(ctx, query, userID, kind, enabled); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -1,20 +1,64 @@
 package store
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Cursor is a keyset position in a list ordered by (created_at, id). It
+// is handed to clients as an opaque signed token, see internal/cursor.
+type Cursor struct {
+	CreatedAt string `json:"t"`
+	ID        int64  `json:"id"`
+}
+
 type PaginatedFeed struct {
 	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
+	// Offset is only honoured when no Cursor is given; it is kept for
+	// clients that predate cursor pagination.
 	Offset int      `json:"offset" validate:"gte=0"`
 	Sort   string   `json:"sort" validate:"oneof=asc desc"`
 	Tags   []string `json:"tags" validate:"max=5"`
 	Search string   `json:"search" validate:"max=100"`
 	Since  string   `json:"since"`
 	Until  string   `json:"until"`
+	Cursor *Cursor  `json:"-"`
+}
+
+// PaginatedQuery is the pagination of list endpoints that only page
+// through a (created_at, id) ordered list.
+type PaginatedQuery struct {
+	Limit  int     `json:"limit" validate:"gte=1,lte=50"`
+	Cursor *Cursor `json:"-"`
+}
+
+func (pq PaginatedQuery) Parse(r *http.Request) (PaginatedQuery, error) {
+	limit := r.URL.Query().Get("limit")
+
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return pq, fmt.Errorf("invalid limit: %w", err)
+		}
+
+		pq.Limit = l
+	}
+
+	return pq, nil
+}
+
+// cursorArgs returns the cursor as query arguments, NULL when absent.
+func cursorArgs(c *Cursor) (sql.NullString, sql.NullInt64) {
+	if c == nil {
+		return sql.NullString{}, sql.NullInt64{}
+	}
+
+	return sql.NullString{String: c.CreatedAt, Valid: true}, sql.NullInt64{Int64: c.ID, Valid: true}
 }
 
 func (fq PaginatedFeed) Parse(r *http.Request) (PaginatedFeed, error) {
@@ -25,7 +69,7 @@ func (fq PaginatedFeed) Parse(r *http.Request) (PaginatedFeed, error) {
 	if limit != "" {
 		l, err := strconv.Atoi(limit)
 		if err != nil {
-			return fq, nil
+			return fq, fmt.Errorf("invalid limit: %w", err)
 		}
 
 		fq.Limit = l
@@ -36,7 +80,7 @@ func (fq PaginatedFeed) Parse(r *http.Request) (PaginatedFeed, error) {
 	if offset != "" {
 		off, err := strconv.Atoi(offset)
 		if err != nil {
-			return fq, nil
+			return fq, fmt.Errorf("invalid offset: %w", err)
 		}
 
 		fq.Offset = off
@@ -49,7 +93,11 @@ func (fq PaginatedFeed) Parse(r *http.Request) (PaginatedFeed, error) {
 	
 	tags := queryString.Get("tags")
 	if tags != "" {
-		fq.Tags = strings.Split(tags, ",")
+		t, err := parseTags(tags)
+		if err != nil {
+			return fq, err
+		}
+		fq.Tags = t
 	}
 	
 	search := queryString.Get("search")
@@ -59,24 +107,56 @@ func (fq PaginatedFeed) Parse(r *http.Request) (PaginatedFeed, error) {
 	
 	since := queryString.Get("since")
 	if since != "" {
-		fq.Since = parseTime(since)
+		t, err := parseTime(since)
+		if err != nil {
+			return fq, fmt.Errorf("invalid since: %w", err)
+		}
+		fq.Since = t
 	}
 
 	until := queryString.Get("until")
 	if until != "" {
-		fq.Until = parseTime(until)
+		t, err := parseTime(until)
+		if err != nil {
+			return fq, fmt.Errorf("invalid until: %w", err)
+		}
+		fq.Until = t
+	}
+
+	if fq.Since != "" && fq.Until != "" && fq.Since > fq.Until {
+		return fq, errors.New("since must not be after until")
 	}
-	
 
 	return fq, nil
 }
 
+// timeLayouts are the layouts accepted for time bounds in query strings.
+var timeLayouts = []string{time.RFC3339, time.DateTime, time.DateOnly}
+
+// parseTime parses a time bound and normalizes it to RFC 3339 in UTC so it
+// can be passed to Postgres and compared as a string.
+func parseTime(s string) (string, error) {
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t.UTC().Format(time.RFC3339), nil
+		}
+	}
+
+	return "", fmt.Errorf("%q is not a valid time, use RFC 3339 (2006-01-02T15:04:05Z), %q or %q", s, time.DateTime, time.DateOnly)
+}
 
-func parseTime(s string)string{
-	t, err := time.Parse(time.DateTime, s)
-	if err != nil{
-		return "Error parsing time"
+// parseTags parses a comma separated list of hashtags into their canonical
+// forms.
+func parseTags(s string) ([]string, error) {
+	tags := strings.Split(s, ",")
+	for i, tag := range tags {
+		normalized, ok := NormalizeTag(strings.TrimSpace(tag))
+		if !ok {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		tags[i] = normalized
 	}
 
-	return t.Format(time.DateTime)
-}
\ No newline at end of file
+	return tags, nil
+}
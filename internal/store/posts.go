@@ -4,58 +4,136 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 type Posts struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Version   int       `json:"version"`
-	Comment   []Comment `json:"comments"`
-	User      Users      `json:"user"`
+	ID         int64     `json:"id"`
+	Content    string    `json:"content"`
+	Title      string    `json:"title"`
+	UserID     int64     `json:"user_id"`
+	Tags       []string  `json:"tags"`
+	CreatedAt  string    `json:"created_at"`
+	UpdatedAt  string    `json:"updated_at"`
+	// Edited is set when the post was changed after it was published.
+	Edited     bool      `json:"edited"`
+	Version    int       `json:"version"`
+	Visibility string    `json:"visibility"`
+	Status     string    `json:"status"`
+	// PublishAt is when a scheduled post is due to be published.
+	PublishAt  *time.Time `json:"publish_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	RepostOfID *int64    `json:"repost_of_id"`
+	QuoteOfID  *int64    `json:"quote_of_id"`
+	Media      []Media   `json:"media"`
+	Mentions   []Mention `json:"mentions"`
+	Comment    []Comment `json:"comments"`
+	User       Users     `json:"user"`
 }
 
 type PostswithMetadata struct {
 	Posts
-	CommentCount int `json:"comment_count"`
+	CommentCount int            `json:"comment_count"`
+	RepostCount  int            `json:"repost_count"`
+	Reactions    map[string]int `json:"reactions"`
+	ReactedByMe  []string       `json:"reacted_by_me"`
+	// RepostedBy is set when the post reached the feed through a repost.
+	RepostedBy *Repost `json:"reposted_by,omitempty"`
 }
+
+// Repost attributes a post in a feed to the user who reposted it.
+type Repost struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Cursor returns the feed position of the post, which is the position of
+// the repost when the post was reposted.
+func (p *PostswithMetadata) Cursor() Cursor {
+	if p.RepostedBy != nil {
+		return Cursor{CreatedAt: p.RepostedBy.CreatedAt, ID: p.RepostedBy.ID}
+	}
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+}
+
 type PostsStore struct {
 	db *sql.DB
 }
 
+// feedEntry is a condition on the post or repost aliased e that holds when
+// it reaches the feed of the user bound to $1: it was written by the user or
+// by an account the user follows, it is tagged with a hashtag the user
+// follows or it is a mentioned-only post mentioning the user. The created_at
+// range of GetUserFeed is bound to $6 and $7.
+func feedEntry(e string) string {
+	return `(
+				` + e + `.user_id = $1 OR
+				` + e + `.user_id IN (SELECT f.user_id FROM followers f WHERE f.follower_id = $1) OR
+				EXISTS (
+					SELECT 1 FROM post_hashtags ph
+					JOIN tag_follows tf ON tf.hashtag_id = ph.hashtag_id
+					WHERE ph.post_id = ` + e + `.id AND tf.user_id = $1
+				) OR
+				(` + e + `.visibility = 'mentioned' AND EXISTS (
+					SELECT 1 FROM mentions m WHERE m.post_id = ` + e + `.id AND m.user_id = $1
+				))
+			) AND
+			($6::timestamptz IS NULL OR ` + e + `.created_at >= $6::timestamptz) AND
+			($7::timestamptz IS NULL OR ` + e + `.created_at <= $7::timestamptz) AND
+			NOT ` + hiddenFrom("$1", e+".user_id") + ` AND
+			` + postLive(e)
+}
+
+// GetUserFeed returns the posts and reposts of the users that UserID
+// follows together with UserID's own, the posts tagged with a hashtag
+// UserID follows and the mentioned-only posts mentioning UserID, optionally
+// narrowed down by tags, a search term and a created_at time range. A post
+// reaching the feed more than once is only returned for its latest entry.
+// Content of users that UserID blocked, muted or was blocked by is left
+// out. Pages continue after pg.Cursor when it is set and fall back to
+// pg.Offset otherwise.
 func (s *PostsStore) GetUserFeed(ctx context.Context, UserID int64, pg PaginatedFeed)([]PostswithMetadata, error){
-	query :=`
-	SELECT 
-		p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
-		u.username,
-		COUNT(c.id) AS comments_count
-		FROM posts p
-		LEFT JOIN comments c ON c.post_id = p.id
-		LEFT JOIN users u ON p.user_id = u.id
-		JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
-		WHERE 
-			f.user_id = $1 AND
-			(
-				COALESCE(p.tags, '{}') @> $2 OR
-				(p.title ILIKE '%' || $3 || '%' OR p.content ILIKE '%' || $3 || '%')
+	// pg.Sort is validated to be asc or desc by the caller
+	keyset := "<"
+	if pg.Sort == "asc" {
+		keyset = ">"
+	}
+
+	offset := pg.Offset
+	if pg.Cursor != nil {
+		offset = 0
+	}
+
+	// an entry is skipped when a later entry of the same post reaches the
+	// feed too, which deduplicates across pages while the keyset and the
+	// limit still bound the scan
+	query := `SELECT ` + feedColumns + `
+		FROM posts e` + feedJoins + `
+		WHERE ` + feedEntry("e") + ` AND
+			(cardinality($2::varchar[]) = 0 OR COALESCE(p.tags, '{}') @> $2::varchar[]) AND
+			($3 = '' OR p.title ILIKE '%' || $3 || '%' OR p.content ILIKE '%' || $3 || '%') AND
+			NOT ` + hiddenFrom("$1", "p.user_id") + ` AND
+			` + postVisibleTo("$1", "p") + ` AND
+			($8::timestamptz IS NULL OR (e.created_at, e.id) ` + keyset + ` ($8::timestamptz, $9)) AND
+			NOT EXISTS (
+				SELECT 1 FROM posts n
+				WHERE (n.id = p.id OR n.repost_of_id = p.id) AND
+					(n.created_at, n.id) > (e.created_at, e.id) AND
+					` + feedEntry("n") + `
 			)
-		GROUP BY p.id, u.username
-		ORDER BY p.created_at `  + pg.Sort + ` 
+		ORDER BY e.created_at `  + pg.Sort + `, e.id ` + pg.Sort + ` 
 		LIMIT $4 OFFSET $5 
 		`
 	
-
-	
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, UserID, pq.Array(pg.Tags), pg.Search, pg.Limit, pg.Offset)
+	cursorAt, cursorID := cursorArgs(pg.Cursor)
+	rows, err := s.db.QueryContext(ctx, query, UserID, pq.Array(pg.Tags), pg.Search, pg.Limit, offset, nullString(pg.Since), nullString(pg.Until), cursorAt, cursorID)
 
 	if err != nil{
 		return nil, err
@@ -63,59 +141,212 @@ func (s *PostsStore) GetUserFeed(ctx context.Context, UserID int64, pg Paginated
 
 	defer rows.Close()
 
-	var feed []PostswithMetadata
+	return scanFeed(rows)
+}
+
+// GetFeedByIDs returns the feed entries, posts or reposts, with the given
+// ids as seen by viewerID, in the order of ids. Ids of entries that no
+// longer exist or that are hidden from the viewer are skipped and a post is
+// only returned for its first entry. The viewer's own drafts and scheduled
+// posts are returned as well.
+func (s *PostsStore) GetFeedByIDs(ctx context.Context, viewerID int64, ids []int64) ([]PostswithMetadata, error) {
+	query := `SELECT ` + feedColumns + `
+		FROM posts e` + feedJoins + `
+		WHERE e.id = ANY($2) AND
+			NOT ` + hiddenFrom("$1", "p.user_id") + ` AND
+			NOT ` + hiddenFrom("$1", "e.user_id") + ` AND
+			` + postReadableBy("$1", "p") + `
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
 
-	for rows.Next(){
+	posts, err := scanFeed(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byEntry := make(map[int64]PostswithMetadata, len(posts))
+	for _, post := range posts {
+		byEntry[post.Cursor().ID] = post
+	}
+
+	feed := make([]PostswithMetadata, 0, len(posts))
+	seen := make(map[int64]bool, len(posts))
+	for _, id := range ids {
+		post, ok := byEntry[id]
+		if !ok || seen[post.ID] {
+			continue
+		}
+
+		seen[post.ID] = true
+		feed = append(feed, post)
+	}
+
+	return feed, nil
+}
+
+// postEditedColumn tells whether the post aliased p was updated after it
+// was published. Publishing dates a post to the moment it is published.
+const postEditedColumn = `(p.status = 'published' AND p.updated_at > p.created_at) AS edited`
+
+// feedJoins joins a feed entry e, which is either a post or a repost, to
+// the post it displays (p), that post's author (u) and the entry's author
+// (eu). Every query selecting feedColumns uses it. Deleted entries, posts
+// and authors are left out.
+const feedJoins = `
+		JOIN posts p ON p.id = COALESCE(e.repost_of_id, e.id) AND e.deleted_at IS NULL AND p.deleted_at IS NULL
+		JOIN users u ON u.id = p.user_id AND u.deleted_at IS NULL
+		JOIN users eu ON eu.id = e.user_id AND eu.deleted_at IS NULL`
+
+// feedColumns is the projection of every query returning PostswithMetadata.
+// It expects the tables of feedJoins and the id of the viewing user to be
+// bound to $1, and has to be kept in sync with scanFeedPost.
+var feedColumns = `
+		p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, ` + postEditedColumn + `, p.version, p.visibility, p.status, p.publish_at, p.tags, p.quote_of_id,
+		u.username,
+		e.id, e.user_id, eu.username, e.created_at,
+		(SELECT COUNT(*) FROM comments c JOIN users cu ON cu.id = c.user_id AND cu.deleted_at IS NULL WHERE c.post_id = p.id) AS comments_count,
+		(SELECT COUNT(*) FROM posts rp WHERE rp.repost_of_id = p.id AND ` + postLive("rp") + `) AS repost_count,` + postReactionColumns + `,` + postMediaColumn + `,` + postMentionsColumn
+
+func scanFeed(rows *sql.Rows) ([]PostswithMetadata, error) {
+	feed := []PostswithMetadata{}
+
+	for rows.Next() {
 		var post PostswithMetadata
-		err := rows.Scan(
-			&post.ID, 
-			&post.UserID, 
-			&post.Title,
-			&post.Content, 
-			&post.CreatedAt, 
-			&post.Version, 
-			pq.Array(&post.Tags), 
-			&post.User.Username, 
-			&post.CommentCount)
-
-		if err != nil{
+		if err := scanFeedPost(rows, &post); err != nil {
 			return nil, err
 		}
 		feed = append(feed, post)
-	
 	}
-	return feed, nil
-}	
-func (s *PostsStore) Create(ctx context.Context, post *Posts) error {
 
-	query := ` 
-		INSERT INTO Posts (content, title, user_id, tags)
-		VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
-	`
+	return feed, rows.Err()
+}
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+// scanFeedPost scans the feedColumns of the current row into post, followed
+// by any extra columns selected after them.
+func scanFeedPost(rows *sql.Rows, post *PostswithMetadata, extra ...any) error {
+	var (
+		entry     Repost
+		reactions []byte
+		media     []byte
+		mentions  []byte
+	)
+
+	dest := []any{
+		&post.ID,
+		&post.UserID,
+		&post.Title,
+		&post.Content,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+		&post.Edited,
+		&post.Version,
+		&post.Visibility,
+		&post.Status,
+		&post.PublishAt,
+		pq.Array(&post.Tags),
+		&post.QuoteOfID,
+		&post.User.Username,
+		&entry.ID,
+		&entry.UserID,
+		&entry.Username,
+		&entry.CreatedAt,
+		&post.CommentCount,
+		&post.RepostCount,
+		&reactions,
+		pq.Array(&post.ReactedByMe),
+		&media,
+		&mentions,
+	}
 
-	err := s.db.QueryRowContext(ctx, query, post.Content, post.Title, post.UserID, pq.Array(post.Tags)).
-		Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	post.User.ID = post.UserID
+	if entry.ID != post.ID {
+		post.RepostedBy = &entry
+	}
 
+	var err error
+	post.Reactions, err = decodeReactionCounts(reactions)
 	if err != nil {
 		return err
 	}
+	post.ReactedByMe = nonNil(post.ReactedByMe)
 
-	return nil
+	if post.Media, err = decodeMedia(media); err != nil {
+		return err
+	}
+
+	post.Mentions, err = decodeMentions(mentions)
+
+	return err
+}
+
+// Create inserts a post with its tags in canonical form, indexes the tags,
+// links the users in post.Mentions and attaches the uploads listed by id in
+// post.Media to the post. Posts are published unless post.Status says
+// otherwise.
+func (s *PostsStore) Create(ctx context.Context, post *Posts) error {
+	post.Tags = NormalizeTags(post.Tags)
+	if post.Visibility == "" {
+		post.Visibility = VisibilityPublic
+	}
+	if post.Status == "" {
+		post.Status = StatusPublished
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := ` 
+			INSERT INTO Posts (content, title, user_id, tags, quote_of_id, visibility, status, publish_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at, updated_at
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, post.Content, post.Title, post.UserID, pq.Array(post.Tags), post.QuoteOfID, post.Visibility, post.Status, post.PublishAt).
+			Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		if err := linkHashtags(ctx, tx, post); err != nil {
+			return err
+		}
+
+		if post.Mentions, err = linkMentions(ctx, tx, "post_id", post.ID, post.UserID, post.Mentions); err != nil {
+			return err
+		}
+
+		return attachMedia(ctx, tx, post)
+	})
 }
 
 func (s *PostsStore) GetbyID(ctx context.Context, postID int64) (*Posts, error) {
-	query := `SELECT id, user_id, title, content, created_at, updated_at, tags, version
-		FROM Posts 
-		WHERE id = $1
+	query := `SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, ` + postEditedColumn + `, p.tags, p.version, p.visibility, p.status, p.publish_at, p.repost_of_id, p.quote_of_id,` + postMediaColumn + `,` + postMentionsColumn + `
+		FROM Posts p
+		WHERE p.id = $1 AND p.deleted_at IS NULL
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var post Posts
-	err := s.db.QueryRowContext(ctx, query, postID).Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.UpdatedAt, pq.Array(&post.Tags), &post.Version)
+	var (
+		post     Posts
+		media    []byte
+		mentions []byte
+	)
+	err := s.db.QueryRowContext(ctx, query, postID).Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.UpdatedAt, &post.Edited, pq.Array(&post.Tags), &post.Version, &post.Visibility, &post.Status, &post.PublishAt, &post.RepostOfID, &post.QuoteOfID, &media, &mentions)
 
 	if err != nil {
 		switch {
@@ -127,11 +358,90 @@ func (s *PostsStore) GetbyID(ctx context.Context, postID int64) (*Posts, error)
 
 	}
 
+	if post.Media, err = decodeMedia(media); err != nil {
+		return nil, err
+	}
+
+	if post.Mentions, err = decodeMentions(mentions); err != nil {
+		return nil, err
+	}
+
 	return &post, nil
 }
 
+// IsVisible reports whether viewerID may see the post, or the reposted post
+// when postID is a repost. Authors may see their own unpublished posts and
+// posts never reach users who blocked or were blocked by their author or
+// reposter.
+func (s *PostsStore) IsVisible(ctx context.Context, postID, viewerID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM posts e
+			JOIN posts p ON p.id = COALESCE(e.repost_of_id, e.id)
+			WHERE e.id = $2 AND e.deleted_at IS NULL AND
+				NOT ` + blockedBetween("$1", "e.user_id") + ` AND
+				NOT ` + blockedBetween("$1", "p.user_id") + ` AND
+				` + postReadableBy("$1", "p") + `
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var visible bool
+	err := s.db.QueryRowContext(ctx, query, viewerID, postID).Scan(&visible)
+
+	return visible, err
+}
+
+// Repost shares postID on behalf of userID. A repost is a post without
+// content of its own that references the reposted post.
+func (s *PostsStore) Repost(ctx context.Context, userID, postID int64) (*Posts, error) {
+	query := `
+		INSERT INTO posts (content, title, user_id, tags, repost_of_id)
+		VALUES ('', '', $1, '{}', $2) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	repost := &Posts{UserID: userID, RepostOfID: &postID, Tags: []string{}, Media: []Media{}, Mentions: []Mention{}, Visibility: VisibilityPublic, Status: StatusPublished}
+	err := s.db.QueryRowContext(ctx, query, userID, postID).Scan(&repost.ID, &repost.CreatedAt, &repost.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+
+	return repost, nil
+}
+
+// Unrepost removes the repost of postID by userID and returns its id.
+func (s *PostsStore) Unrepost(ctx context.Context, userID, postID int64) (int64, error) {
+	query := `DELETE FROM posts WHERE user_id = $1 AND repost_of_id = $2 RETURNING id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, query, userID, postID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// DeletebyID soft deletes a post. It is left out of every query from then
+// on and can be restored until it is purged.
 func (s *PostsStore) DeletebyID(ctx context.Context, postID int64) error {
-	query := "DELETE FROM posts WHERE id = $1"
+	query := "UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL"
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -155,29 +465,221 @@ func (s *PostsStore) DeletebyID(ctx context.Context, postID int64) error {
 	return nil
 }
 
-func (s *PostsStore) UpdatebyID(ctx context.Context, post *Posts) error {
+// GetDeleted returns the author and deletion time of a soft deleted post.
+func (s *PostsStore) GetDeleted(ctx context.Context, postID int64) (*Posts, error) {
+	query := `SELECT id, user_id, deleted_at FROM posts WHERE id = $1 AND deleted_at IS NOT NULL`
 
-	query := `
-		UPDATE posts 
-		SET 
-		title = COALESCE($1, title), 
-		content = COALESCE($2, content),
-		version = version + 1
-		WHERE id = $3 AND version = $4
-		RETURNING version
-	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, post.Title, post.Content, post.ID, post.Version).Scan(&post.Version)
-
+	var post Posts
+	err := s.db.QueryRowContext(ctx, query, postID).Scan(&post.ID, &post.UserID, &post.DeletedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
+			return nil, ErrNotFound
 		default:
-			return err
+			return nil, err
 		}
 	}
+
+	return &post, nil
+}
+
+// Restore undoes the deletion of a post deleted less than window ago.
+func (s *PostsStore) Restore(ctx context.Context, postID int64, window time.Duration) error {
+	query := `
+		UPDATE posts SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at >= NOW() - $2 * interval '1 second'
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, postID, int64(window.Seconds()))
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
 	return nil
 }
+
+// Purge removes the posts deleted more than retention ago for good, along
+// with their reposts, comments, reactions and media, and returns the keys
+// of the blobs of that media.
+func (s *PostsStore) Purge(ctx context.Context, retention time.Duration) ([]string, error) {
+	query := `
+		WITH purged AS (
+			DELETE FROM posts WHERE deleted_at < NOW() - $1 * interval '1 second'
+			RETURNING id
+		)
+		SELECT unnest(ARRAY[m.key, m.thumbnail_key])
+		FROM media m
+		JOIN purged ON purged.id = m.post_id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return queryStrings(ctx, s.db, query, int64(retention.Seconds()))
+}
+
+// UpdatebyID saves the title, content, tags, visibility, status and publish
+// time of a post and relinks its tags and the users in post.Mentions. A post
+// that gets published is dated to the moment of publishing. Every update is
+// kept as a revision of the post.
+func (s *PostsStore) UpdatebyID(ctx context.Context, post *Posts) error {
+	post.Tags = NormalizeTags(post.Tags)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE posts p
+			SET 
+			title = COALESCE($1, title), 
+			content = COALESCE($2, content),
+			tags = $8,
+			visibility = $5,
+			created_at = CASE WHEN status <> 'published' AND $6 = 'published' THEN NOW() ELSE created_at END,
+			status = $6,
+			publish_at = $7,
+			updated_at = NOW(),
+			version = version + 1
+			WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+			RETURNING version, created_at, updated_at, ` + postEditedColumn + `
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, post.Title, post.Content, post.ID, post.Version, post.Visibility, post.Status, post.PublishAt, pq.Array(post.Tags)).Scan(&post.Version, &post.CreatedAt, &post.UpdatedAt, &post.Edited)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		// relinking the tags also dates them to the moment a post gets
+		// published
+		if err := relinkHashtags(ctx, tx, post); err != nil {
+			return err
+		}
+
+		post.Mentions, err = linkMentions(ctx, tx, "post_id", post.ID, post.UserID, post.Mentions)
+
+		return err
+	})
+}
+
+// GetUnpublished returns the posts of userID with the given status, draft
+// or scheduled, newest first.
+func (s *PostsStore) GetUnpublished(ctx context.Context, userID int64, status string, pg PaginatedQuery) ([]PostswithMetadata, error) {
+	query := `SELECT ` + feedColumns + `
+		FROM posts e` + feedJoins + `
+		WHERE e.user_id = $1 AND e.status = $2 AND
+			($3::timestamptz IS NULL OR (e.created_at, e.id) < ($3::timestamptz, $4))
+		ORDER BY e.created_at DESC, e.id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cursorAt, cursorID := cursorArgs(pg.Cursor)
+	rows, err := s.db.QueryContext(ctx, query, userID, status, cursorAt, cursorID, pg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFeed(rows)
+}
+
+// PublishDue publishes up to limit scheduled posts whose publish time has
+// come and returns them. Due posts are claimed with SKIP LOCKED, so that
+// concurrent schedulers never publish the same post twice.
+func (s *PostsStore) PublishDue(ctx context.Context, limit int) ([]Posts, error) {
+	var ids []int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			WITH due AS (
+				SELECT id FROM posts
+				WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+				ORDER BY publish_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			UPDATE posts p
+			SET status = 'published', created_at = NOW(), updated_at = NOW(), version = version + 1
+			FROM due
+			WHERE p.id = due.id
+			RETURNING p.id
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := redateHashtags(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]Posts, 0, len(ids))
+	for _, id := range ids {
+		post, err := s.GetbyID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrNotFound):
+				// deleted right after it was published
+				continue
+			default:
+				return nil, err
+			}
+		}
+		posts = append(posts, *post)
+	}
+
+	return posts, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// ReactionKinds is the fixed set of reactions, mirrored by the check
+// constraint on reactions.kind.
+var ReactionKinds = []string{"like", "love", "haha", "wow", "sad", "angry"}
+
+func IsReactionKind(kind string) bool {
+	for _, k := range ReactionKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Reaction targets either a post or a comment.
+type Reaction struct {
+	UserID    int64  `json:"user_id"`
+	PostID    *int64 `json:"post_id"`
+	CommentID *int64 `json:"comment_id"`
+	Kind      string `json:"kind"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ReactionsStore struct {
+	db *sql.DB
+}
+
+// Add stores a reaction; adding a reaction that already exists is a no-op.
+func (s *ReactionsStore) Add(ctx context.Context, reaction *Reaction) error {
+	query := `
+		INSERT INTO reactions (user_id, post_id, comment_id, kind)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, reaction.UserID, reaction.PostID, reaction.CommentID, reaction.Kind)
+
+	return err
+}
+
+// Remove deletes a reaction; removing a missing reaction is a no-op.
+func (s *ReactionsStore) Remove(ctx context.Context, reaction *Reaction) error {
+	query := `
+		DELETE FROM reactions
+		WHERE user_id = $1 AND kind = $2
+		AND post_id IS NOT DISTINCT FROM $3 AND comment_id IS NOT DISTINCT FROM $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, reaction.UserID, reaction.Kind, reaction.PostID, reaction.CommentID)
+
+	return err
+}
+
+// postReactionColumns and commentReactionColumns select the reaction
+// counts by kind as a JSON object and the kinds the viewer, bound to $1,
+// reacted with. They expect the post to be aliased as p and the comment
+// as c.
+const (
+	postReactionColumns = `
+		(SELECT COALESCE(json_object_agg(r.kind, r.count), '{}') FROM (
+			SELECT kind, COUNT(*) AS count FROM reactions WHERE post_id = p.id GROUP BY kind
+		) r) AS reactions,
+		ARRAY(SELECT kind FROM reactions WHERE post_id = p.id AND user_id = $1 ORDER BY kind) AS reacted_by_me`
+
+	commentReactionColumns = `
+		(SELECT COALESCE(json_object_agg(r.kind, r.count), '{}') FROM (
+			SELECT kind, COUNT(*) AS count FROM reactions WHERE comment_id = c.id GROUP BY kind
+		) r) AS reactions,
+		ARRAY(SELECT kind FROM reactions WHERE comment_id = c.id AND user_id = $1 ORDER BY kind) AS reacted_by_me`
+)
+
+func decodeReactionCounts(data []byte) (map[string]int, error) {
+	counts := map[string]int{}
+	if err := json.Unmarshal(data, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// PostRevision is a post as it was at one of its versions.
+type PostRevision struct {
+	PostID     int64  `json:"post_id"`
+	Version    int    `json:"version"`
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Visibility string `json:"visibility"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type RevisionsStore struct {
+	db *sql.DB
+}
+
+// GetByPostID returns the revisions of a post, newest first. Revisions are
+// recorded by a trigger whenever the version of a post changes.
+func (s *RevisionsStore) GetByPostID(ctx context.Context, postID int64) ([]PostRevision, error) {
+	query := `
+		SELECT post_id, version, title, content, visibility, created_at
+		FROM post_revisions
+		WHERE post_id = $1
+		ORDER BY version DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		var rev PostRevision
+		if err := rows.Scan(&rev.PostID, &rev.Version, &rev.Title, &rev.Content, &rev.Visibility, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
@@ -0,0 +1,307 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+const (
+	SearchPosts = "posts"
+	SearchUsers = "users"
+)
+
+// SearchQuery is a search request. Posts are matched on their title,
+// content and tags and can be narrowed down by author, tags and a
+// created_at range; users are matched on username and display name.
+type SearchQuery struct {
+	Query  string   `json:"q" validate:"required,max=100"`
+	Type   string   `json:"type" validate:"oneof=posts users"`
+	Author string   `json:"author" validate:"max=100"`
+	Tags   []string `json:"tags" validate:"max=5"`
+	Since  string   `json:"since"`
+	Until  string   `json:"until"`
+	Limit  int      `json:"limit" validate:"gte=1,lte=50"`
+	Offset int      `json:"offset" validate:"gte=0,lte=1000"`
+}
+
+func (sq SearchQuery) Parse(r *http.Request) (SearchQuery, error) {
+	queryString := r.URL.Query()
+
+	sq.Query = strings.TrimSpace(queryString.Get("q"))
+
+	if t := queryString.Get("type"); t != "" {
+		sq.Type = t
+	}
+
+	sq.Author = queryString.Get("author")
+
+	var err error
+	if tags := queryString.Get("tags"); tags != "" {
+		if sq.Tags, err = parseTags(tags); err != nil {
+			return sq, err
+		}
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &sq.Limit}, {"offset", &sq.Offset}} {
+		if v := queryString.Get(param.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return sq, fmt.Errorf("invalid %s: %w", param.name, err)
+			}
+			*param.dst = n
+		}
+	}
+
+	if since := queryString.Get("since"); since != "" {
+		if sq.Since, err = parseTime(since); err != nil {
+			return sq, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+
+	if until := queryString.Get("until"); until != "" {
+		if sq.Until, err = parseTime(until); err != nil {
+			return sq, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+
+	if sq.Since != "" && sq.Until != "" && sq.Since > sq.Until {
+		return sq, errors.New("since must not be after until")
+	}
+
+	return sq, nil
+}
+
+// PostSearchResult is a post matching a search, with its relevance and an
+// excerpt of its content. The snippet is HTML-escaped and the matches in it
+// are wrapped in <mark> elements.
+type PostSearchResult struct {
+	PostswithMetadata
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// UserSearchResult is a user matching a search.
+type UserSearchResult struct {
+	UserSummary
+	DisplayName string  `json:"display_name"`
+	AvatarURL   string  `json:"avatar_url"`
+	Score       float64 `json:"score"`
+}
+
+// ErrEmptySearch is returned when a search query has no searchable terms.
+var ErrEmptySearch = errors.New("search query has no searchable terms")
+
+type SearchStore struct {
+	db *sql.DB
+}
+
+// Posts returns the original posts matching sq as seen by viewerID, most
+// relevant first. Title matches weigh more than content matches, which
+// weigh more than tag matches.
+func (s *SearchStore) Posts(ctx context.Context, viewerID int64, sq SearchQuery) ([]PostSearchResult, error) {
+	tsquery := buildTSQuery(sq.Query)
+	if tsquery == "" {
+		return nil, ErrEmptySearch
+	}
+
+	query := `SELECT ` + feedColumns + `,
+			ts_rank_cd(p.search_vector, q.query) AS rank,
+			ts_headline('english',
+				replace(replace(replace(p.content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), q.query,
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10') AS snippet
+		FROM posts e` + feedJoins + `
+		CROSS JOIN to_tsquery('english', $2) AS q(query)
+		WHERE e.repost_of_id IS NULL AND
+			p.search_vector @@ q.query AND
+			($3 = '' OR u.username = $3) AND
+			(cardinality($4::varchar[]) = 0 OR COALESCE(p.tags, '{}') @> $4::varchar[]) AND
+			($5::timestamptz IS NULL OR p.created_at >= $5::timestamptz) AND
+			($6::timestamptz IS NULL OR p.created_at <= $6::timestamptz) AND
+			NOT ` + hiddenFrom("$1", "p.user_id") + ` AND
+			` + postVisibleTo("$1", "p") + `
+		ORDER BY rank DESC, p.created_at DESC, p.id DESC
+		LIMIT $7 OFFSET $8
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, tsquery, sq.Author, pq.Array(sq.Tags), nullString(sq.Since), nullString(sq.Until), sq.Limit, sq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []PostSearchResult{}
+	for rows.Next() {
+		var r PostSearchResult
+		if err := scanFeedPost(rows, &r.PostswithMetadata, &r.Rank, &r.Snippet); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// Users returns the active users whose username or display name resembles
+// sq.Query, leaving out users blocked by or blocking viewerID. An exact
+// username match comes first, then the closest matches.
+func (s *SearchStore) Users(ctx context.Context, viewerID int64, sq SearchQuery) ([]UserSearchResult, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url,
+			GREATEST(similarity(u.username, $2), similarity(u.display_name, $2)) AS score
+		FROM users u
+		WHERE u.is_active = true AND u.deleted_at IS NULL AND
+			(
+				u.username % $2 OR u.display_name % $2 OR
+				u.username ILIKE '%' || $3 || '%' OR u.display_name ILIKE '%' || $3 || '%'
+			) AND
+			NOT ` + blockedBetween("$1", "u.id") + `
+		ORDER BY lower(u.username) = lower($2) DESC, score DESC, u.id
+		LIMIT $4 OFFSET $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, sq.Query, escapeLike(sq.Query), sq.Limit, sq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []UserSearchResult{}
+	for rows.Next() {
+		var r UserSearchResult
+		if err := rows.Scan(&r.ID, &r.Username, &r.DisplayName, &r.AvatarURL, &r.Score); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// buildTSQuery turns a search typed by a user into a to_tsquery expression.
+// Words are ANDed, "quoted words" must appear as a phrase, a trailing * makes
+// a word a prefix, a leading - excludes a word or phrase and OR between two
+// terms makes either match. Anything else that is not a letter or a digit
+// separates words, so the result is always a valid expression. It returns
+// an empty string when nothing positive is left to search for.
+func buildTSQuery(q string) string {
+	var (
+		b        strings.Builder
+		positive bool
+		or       bool
+	)
+
+	for _, tok := range tokenizeSearch(q) {
+		if !tok.phrase && tok.text == "OR" {
+			or = b.Len() > 0
+			continue
+		}
+
+		text, negate := tok.text, false
+		if strings.HasPrefix(text, "-") {
+			text, negate = text[1:], true
+		}
+
+		prefix := false
+		if !tok.phrase && strings.HasSuffix(text, "*") {
+			text, prefix = strings.TrimRight(text, "*"), true
+		}
+
+		lexemes := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(lexemes) == 0 {
+			continue
+		}
+
+		if prefix {
+			lexemes[len(lexemes)-1] += ":*"
+		}
+
+		term := strings.Join(lexemes, " <-> ")
+		if len(lexemes) > 1 {
+			term = "(" + term + ")"
+		}
+
+		if negate {
+			term = "!" + term
+		} else {
+			positive = true
+		}
+
+		if b.Len() > 0 {
+			if or && !negate {
+				b.WriteString(" | ")
+			} else {
+				b.WriteString(" & ")
+			}
+		}
+		or = false
+
+		b.WriteString(term)
+	}
+
+	if !positive {
+		return ""
+	}
+
+	return b.String()
+}
+
+type searchToken struct {
+	text   string
+	phrase bool
+}
+
+// tokenizeSearch splits a search into whitespace separated words and
+// double quoted phrases. A phrase may be negated with a leading -.
+func tokenizeSearch(q string) []searchToken {
+	var tokens []searchToken
+
+	for q = strings.TrimSpace(q); q != ""; q = strings.TrimSpace(q) {
+		negate := ""
+		if strings.HasPrefix(q, `-"`) {
+			negate, q = "-", q[1:]
+		}
+
+		if strings.HasPrefix(q, `"`) {
+			end := strings.Index(q[1:], `"`)
+			if end < 0 {
+				end = len(q) - 1
+			}
+			tokens = append(tokens, searchToken{text: negate + q[1:end+1], phrase: true})
+			q = q[min(end+2, len(q)):]
+			continue
+		}
+
+		end := strings.IndexFunc(q, unicode.IsSpace)
+		if end < 0 {
+			end = len(q)
+		}
+		tokens = append(tokens, searchToken{text: q[:end]})
+		q = q[end:]
+	}
+
+	return tokens
+}
+
+// escapeLike escapes the LIKE wildcards in s so that it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
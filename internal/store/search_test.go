@@ -0,0 +1,30 @@
+package store
+
+import "testing"
+
+func TestBuildTSQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"go lang", "go & lang"},
+		{`"quick brown fox" jumps`, "(quick <-> brown <-> fox) & jumps"},
+		{"data*", "data:*"},
+		{"cats OR dogs", "cats | dogs"},
+		{"cats -dogs", "cats & !dogs"},
+		{`cats -"hot dogs"`, "cats & !(hot <-> dogs)"},
+		{"it's", "(it <-> s)"},
+		{"Go!", "go"},
+		{`"unterminated phrase`, "(unterminated <-> phrase)"},
+		{"OR cats", "cats"},
+		{"-dogs", ""},
+		{`&|!():* <->`, ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := buildTSQuery(tt.in); got != tt.want {
+			t.Errorf("buildTSQuery(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
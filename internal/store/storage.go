@@ -20,6 +20,15 @@ type Storage struct {
 		DeletebyID(context.Context, int64) error
 		UpdatebyID(context.Context, *Posts) error
 		GetUserFeed(context.Context, int64, PaginatedFeed)([]PostswithMetadata, error)
+		GetFeedByIDs(context.Context, int64, []int64)([]PostswithMetadata, error)
+		Repost(context.Context, int64, int64) (*Posts, error)
+		Unrepost(context.Context, int64, int64) (int64, error)
+		IsVisible(context.Context, int64, int64) (bool, error)
+		GetUnpublished(context.Context, int64, string, PaginatedQuery) ([]PostswithMetadata, error)
+		PublishDue(context.Context, int) ([]Posts, error)
+		GetDeleted(context.Context, int64) (*Posts, error)
+		Restore(context.Context, int64, time.Duration) error
+		Purge(context.Context, time.Duration) ([]string, error)
 	}
 	Users interface {
 		Create(context.Context, *sql.Tx, *Users) error
@@ -28,18 +37,106 @@ type Storage struct {
 		Activation(context.Context, string) error
 		Delete(context.Context, int64)error
 		GetByEmail(context.Context, string)(*Users, error)
+		CreatePasswordReset(context.Context, int64, string, time.Duration) error
+		ResetPassword(context.Context, string, *Password) (int64, error)
+		SetPrivate(context.Context, int64, bool) error
+		GetProfile(context.Context, int64, int64) (*Profile, error)
+		UpdateProfile(context.Context, *Users, time.Duration) error
+		ResolveUsername(context.Context, string) (int64, string, error)
+		SetAvatar(context.Context, int64, string, string) (string, error)
+		SoftDelete(context.Context, int64) error
+		Restore(context.Context, int64, time.Duration) error
+		Purge(context.Context, time.Duration) ([]string, error)
 	}
 	Comments interface{
-		GetbyPostID(context.Context, int64)([]Comment, error)
+		GetbyPostID(context.Context, int64, int64)([]Comment, error)
 		Create(context.Context, *Comment)error
+		GetbyID(context.Context, int64)(*Comment, error)
+		UpdatebyID(context.Context, *Comment)error
+		DeletebyID(context.Context, int64)error
 	}
 	Followers interface{
-		Follow(ctx context.Context, FollowerID, userID int64) error
+		Follow(ctx context.Context, FollowerID, userID int64) (bool, error)
 		Unfollow(ctx context.Context, FollowerID, userID int64) error
+		GetRequests(ctx context.Context, userID int64, pg PaginatedQuery) ([]FollowRequest, error)
+		ApproveRequest(ctx context.Context, userID, followerID int64) error
+		DenyRequest(ctx context.Context, userID, followerID int64) error
+		GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error)
+		GetFollowers(ctx context.Context, userID, viewerID int64, pg PaginatedQuery) ([]Connection, error)
+		GetFollowing(ctx context.Context, userID, viewerID int64, pg PaginatedQuery) ([]Connection, error)
+		FollowsPopular(ctx context.Context, followerID int64, minFollowers int) (bool, error)
 	}
 	Roles interface{
 		GetByName(context.Context, string)(*Roles, error)
 	}
+	Reactions interface{
+		Add(context.Context, *Reaction) error
+		Remove(context.Context, *Reaction) error
+	}
+	Bookmarks interface{
+		Save(context.Context, int64, int64, *int64) error
+		Remove(context.Context, int64, int64) error
+		List(context.Context, int64, *int64, PaginatedQuery) ([]BookmarkedPost, error)
+		CreateCollection(context.Context, *BookmarkCollection) error
+		GetCollections(context.Context, int64) ([]BookmarkCollection, error)
+		DeleteCollection(context.Context, int64, int64) error
+	}
+	Tokens interface{
+		Create(context.Context, int64, string, time.Duration) error
+		Rotate(context.Context, string, string, time.Duration) (*RefreshToken, error)
+		RevokeFamily(context.Context, int64, string) error
+		Deny(context.Context, string, time.Time) error
+		IsDenied(context.Context, string) (bool, error)
+		PurgeExpired(context.Context) error
+	}
+	Notifications interface{
+		Create(context.Context, *Notification) error
+		List(context.Context, int64, bool, PaginatedQuery) ([]NotificationGroup, error)
+		CountUnread(context.Context, int64) (int, error)
+		MarkRead(context.Context, int64, []int64) error
+		GetPreferences(context.Context, int64) (map[string]bool, error)
+		UpdatePreferences(context.Context, int64, map[string]bool) error
+	}
+	Blocks interface{
+		Block(context.Context, int64, int64) error
+		Unblock(context.Context, int64, int64) error
+		Mute(context.Context, int64, int64) error
+		Unmute(context.Context, int64, int64) error
+		IsBlocked(context.Context, int64, int64) (bool, error)
+	}
+	Conversations interface{
+		Create(context.Context, *Conversation, []int64) error
+		FindDirect(context.Context, int64, int64) (int64, error)
+		GetByID(context.Context, int64, int64) (*Conversation, error)
+		List(context.Context, int64, string, PaginatedQuery) ([]Conversation, error)
+		CountUnread(context.Context, int64) (int, error)
+		SendMessage(context.Context, *Message) error
+		GetMessages(context.Context, int64, PaginatedQuery) ([]Message, error)
+		MarkRead(context.Context, int64, int64, int64) error
+		SetMuted(context.Context, int64, int64, bool) error
+		Accept(context.Context, int64, int64) error
+		Leave(context.Context, int64, int64) error
+	}
+	Media interface{
+		Create(context.Context, *Media) error
+		PurgeUnattached(context.Context, time.Duration) ([]string, error)
+	}
+	Hashtags interface{
+		GetPosts(context.Context, string, int64, PaginatedQuery) ([]PostswithMetadata, error)
+		Trending(context.Context, time.Duration, int, int) ([]TrendingTag, error)
+		Follow(context.Context, int64, string) error
+		Unfollow(context.Context, int64, string) error
+		GetFollowed(context.Context, int64) ([]Hashtag, error)
+		FollowsAny(context.Context, int64) (bool, error)
+		Backfill(context.Context, int) (int, error)
+	}
+	Search interface{
+		Posts(context.Context, int64, SearchQuery) ([]PostSearchResult, error)
+		Users(context.Context, int64, SearchQuery) ([]UserSearchResult, error)
+	}
+	Revisions interface{
+		GetByPostID(context.Context, int64) ([]PostRevision, error)
+	}
 
 }
 
@@ -50,6 +147,16 @@ func NewPostgresStorage (db *sql.DB) Storage{
 		Comments: &CommentsStore{db},
 		Followers: &FollowersStore{db},
 		Roles: &RolesStore{db},
+		Tokens: &TokensStore{db},
+		Reactions: &ReactionsStore{db},
+		Bookmarks: &BookmarksStore{db},
+		Notifications: &NotificationsStore{db},
+		Conversations: &ConversationsStore{db},
+		Blocks: &BlocksStore{db},
+		Media: &MediaStore{db},
+		Search: &SearchStore{db},
+		Hashtags: &HashtagsStore{db},
+		Revisions: &RevisionsStore{db},
 	}
 }
 
@@ -67,4 +174,24 @@ func withTx(db *sql.DB, ctx context.Context, fn func (*sql.Tx) error)error{
 		return err
 	}
 	return tx.Commit()
-}
\ No newline at end of file
+}
+// queryStrings runs a query selecting a single text column and returns its
+// values.
+func queryStrings(ctx context.Context, db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var ErrTokenReused = errors.New("refresh token has already been used")
+
+type RefreshToken struct {
+	ID       int64     `json:"id"`
+	UserID   int64     `json:"user_id"`
+	FamilyID string    `json:"family_id"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+type TokensStore struct {
+	db *sql.DB
+}
+
+// Create stores a refresh token that starts a new token family.
+func (s *TokensStore) Create(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	query := `INSERT INTO refresh_tokens (token, user_id, expiry) VALUES ($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, hashToken(token), userID, time.Now().Add(exp))
+
+	return err
+}
+
+// Rotate consumes a refresh token and stores its replacement in the same
+// family. Presenting a token that was already consumed means it leaked, so
+// the whole family is revoked and ErrTokenReused is returned.
+func (s *TokensStore) Rotate(ctx context.Context, token, newToken string, exp time.Duration) (*RefreshToken, error) {
+	var (
+		rt        RefreshToken
+		revokedAt sql.NullTime
+	)
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT id, user_id, family_id, expiry, revoked_at
+			FROM refresh_tokens
+			WHERE token = $1
+			FOR UPDATE
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, hashToken(token)).Scan(&rt.ID, &rt.UserID, &rt.FamilyID, &rt.Expiry, &revokedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if revokedAt.Valid {
+			return ErrTokenReused
+		}
+
+		if rt.Expiry.Before(time.Now()) {
+			return ErrNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW() WHERE id = $1`, rt.ID); err != nil {
+			return err
+		}
+
+		rt.Expiry = time.Now().Add(exp)
+
+		query = `
+			INSERT INTO refresh_tokens (token, user_id, family_id, expiry)
+			VALUES ($1, $2, $3, $4) RETURNING id
+		`
+		return tx.QueryRowContext(ctx, query, hashToken(newToken), rt.UserID, rt.FamilyID, rt.Expiry).Scan(&rt.ID)
+	})
+
+	if errors.Is(err, ErrTokenReused) {
+		// the revocation has to outlive the rolled back transaction
+		if err := s.revokeFamily(ctx, rt.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenReused
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rt, nil
+}
+
+// RevokeFamily revokes every token of the family the given refresh token
+// belongs to, provided that it was issued to userID.
+func (s *TokensStore) RevokeFamily(ctx context.Context, userID int64, token string) error {
+	query := `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token = $1 AND user_id = $2)
+		AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, hashToken(token), userID)
+
+	return err
+}
+
+func (s *TokensStore) revokeFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, familyID)
+
+	return err
+}
+
+// Deny adds an access token id to the denylist until the token expires.
+func (s *TokensStore) Deny(ctx context.Context, jti string, exp time.Time) error {
+	query := `INSERT INTO revoked_tokens (jti, expiry) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, jti, exp)
+
+	return err
+}
+
+func (s *TokensStore) IsDenied(ctx context.Context, jti string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expiry > NOW())`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var denied bool
+	err := s.db.QueryRowContext(ctx, query, jti).Scan(&denied)
+
+	return denied, err
+}
+
+// PurgeExpired removes the denied access tokens that expired, as they are
+// rejected for their expiry alone.
+func (s *TokensStore) PurgeExpired(ctx context.Context) error {
+	query := `DELETE FROM revoked_tokens WHERE expiry <= NOW()`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query)
+
+	return err
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
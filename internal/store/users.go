@@ -8,12 +8,14 @@ import (
 	"errors"
 	"time"
 
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	ErrDuplicateEmail    = errors.New("a user with that email already exists")
 	ErrDuplicateUsername = errors.New("a user with that username already exists")
+	ErrUsernameCooldown  = errors.New("the username was changed too recently")
 )
 
 type UserStore struct {
@@ -21,14 +23,37 @@ type UserStore struct {
 }
 
 type Users struct {
-	ID        int64    `json:"id"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  Password `json:"-"`
-	CreatedAt string   `json:"created_at"`
-	IsActive  bool     `json:"is_active"`
-	RoleID    int64    `json:"role_id"`
-	Role      Roles    `json:"role"`
+	ID          int64    `json:"id"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	Password    Password `json:"-"`
+	CreatedAt   string   `json:"created_at"`
+	IsActive    bool     `json:"is_active"`
+	IsPrivate   bool     `json:"is_private"`
+	DisplayName string   `json:"display_name"`
+	Bio         string   `json:"bio"`
+	Website     string   `json:"website"`
+	Location    string   `json:"location"`
+	Pronouns    string   `json:"pronouns"`
+	AvatarURL   string   `json:"avatar_url"`
+	RoleID      int64    `json:"role_id"`
+	Role        Roles    `json:"role"`
+	// TokensValidAfter is set once the password was reset; access tokens
+	// issued before it, which includes the whole second of the reset, are
+	// no longer accepted.
+	TokensValidAfter *time.Time `json:"tokens_valid_after,omitempty"`
+}
+
+// Profile is a user as presented to a viewer, with the counters maintained
+// on the users table and the relationship between the two.
+type Profile struct {
+	Users
+	FollowersCount int64 `json:"followers_count"`
+	FollowingCount int64 `json:"following_count"`
+	PostsCount     int64 `json:"posts_count"`
+	FollowedByMe   bool  `json:"followed_by_me"`
+	FollowsMe      bool  `json:"follows_me"`
+	RequestedByMe  bool  `json:"requested_by_me"`
 }
 
 type Password struct {
@@ -47,6 +72,11 @@ func (pass *Password) Set(text string) error {
 	pass.hash = hash
 	return nil
 }
+
+func (pass *Password) Compare(text string) error {
+	return bcrypt.CompareHashAndPassword(pass.hash, []byte(text))
+}
+
 func (u *UserStore) Create(ctx context.Context, tx *sql.Tx, user *Users) error {
 	query := ` 
 		INSERT INTO Users (username, email, password, role_id)
@@ -76,9 +106,9 @@ func (u *UserStore) Create(ctx context.Context, tx *sql.Tx, user *Users) error {
 }
 
 func (s *UserStore) GetUser(ctx context.Context, userId int64) (*Users, error) {
-	query := `SELECT users.id, username, email, created_at, roles.* FROM users 
+	query := `SELECT users.id, username, email, created_at, is_private, display_name, bio, website, location, pronouns, avatar_url, tokens_valid_after, roles.* FROM users 
 	JOIN roles ON (users.role_id = roles.id)
-	WHERE users.id = $1 AND is_active = true
+	WHERE users.id = $1 AND is_active = true AND deleted_at IS NULL
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -87,7 +117,7 @@ func (s *UserStore) GetUser(ctx context.Context, userId int64) (*Users, error) {
 	var user Users
 	err := s.db.QueryRowContext(ctx, query, userId).
 	Scan(&user.ID, &user.Username, 
-		&user.Email, &user.CreatedAt, &user.Role.ID, &user.Role.Name, &user.Role.Level, &user.Role.Description)
+		&user.Email, &user.CreatedAt, &user.IsPrivate, &user.DisplayName, &user.Bio, &user.Website, &user.Location, &user.Pronouns, &user.AvatarURL, &user.TokensValidAfter, &user.Role.ID, &user.Role.Name, &user.Role.Level, &user.Role.Description)
 
 	if err != nil {
 		switch {
@@ -102,6 +132,194 @@ func (s *UserStore) GetUser(ctx context.Context, userId int64) (*Users, error) {
 	return &user, nil
 }
 
+// GetProfile returns the profile of an active user as seen by viewerID.
+func (s *UserStore) GetProfile(ctx context.Context, userID, viewerID int64) (*Profile, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.created_at, u.is_private, u.display_name, u.bio, u.website, u.location, u.pronouns, u.avatar_url,
+			r.id, r.name, r.level, r.description, u.followers_count, u.following_count, u.posts_count,
+			EXISTS (SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $2),
+			EXISTS (SELECT 1 FROM followers f WHERE f.user_id = $2 AND f.follower_id = u.id),
+			EXISTS (SELECT 1 FROM follow_requests fr WHERE fr.user_id = u.id AND fr.follower_id = $2)
+		FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE u.id = $1 AND u.is_active = true AND u.deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var p Profile
+	err := s.db.QueryRowContext(ctx, query, userID, viewerID).Scan(
+		&p.ID, &p.Username, &p.Email, &p.CreatedAt, &p.IsPrivate, &p.DisplayName, &p.Bio, &p.Website, &p.Location, &p.Pronouns, &p.AvatarURL, &p.Role.ID, &p.Role.Name, &p.Role.Level, &p.Role.Description,
+		&p.FollowersCount, &p.FollowingCount, &p.PostsCount,
+		&p.FollowedByMe, &p.FollowsMe, &p.RequestedByMe,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	p.RoleID = p.Role.ID
+	p.IsActive = true
+
+	return &p, nil
+}
+
+// ResolveUsername returns the id and current username of the active user
+// known by username, either now or, for a renamed user, in the past. A
+// current username takes precedence over an old one.
+func (s *UserStore) ResolveUsername(ctx context.Context, username string) (int64, string, error) {
+	query := `
+		SELECT u.id, u.username
+		FROM users u
+		WHERE u.is_active = true AND u.deleted_at IS NULL AND (
+			u.username = $1 OR
+			u.id = (SELECT h.user_id FROM username_history h WHERE h.username = $1 ORDER BY h.changed_at DESC, h.id DESC LIMIT 1)
+		)
+		ORDER BY u.username = $1 DESC
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		id      int64
+		current string
+	)
+	err := s.db.QueryRowContext(ctx, query, username).Scan(&id, &current)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, "", ErrNotFound
+		default:
+			return 0, "", err
+		}
+	}
+
+	return id, current, nil
+}
+
+// UpdateProfile saves the username and profile fields of a user. A changed
+// username is kept in the username history so that links to the old one
+// still resolve, and can only be changed again once cooldown has passed.
+func (s *UserStore) UpdateProfile(ctx context.Context, user *Users, cooldown time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var (
+			username  string
+			changedAt sql.NullTime
+		)
+		query := `SELECT username, username_changed_at FROM users WHERE id = $1 FOR UPDATE`
+		if err := tx.QueryRowContext(ctx, query, user.ID).Scan(&username, &changedAt); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		renamed := username != user.Username
+		if renamed {
+			if changedAt.Valid && time.Since(changedAt.Time) < cooldown {
+				return ErrUsernameCooldown
+			}
+
+			query := `INSERT INTO username_history (user_id, username) VALUES ($1, $2)`
+			if _, err := tx.ExecContext(ctx, query, user.ID, username); err != nil {
+				return err
+			}
+		}
+
+		query = `
+			UPDATE users
+			SET username = $2, display_name = $3, bio = $4, website = $5, location = $6, pronouns = $7,
+				username_changed_at = CASE WHEN $8 THEN NOW() ELSE username_changed_at END
+			WHERE id = $1
+		`
+		_, err := tx.ExecContext(ctx, query, user.ID, user.Username, user.DisplayName, user.Bio, user.Website, user.Location, user.Pronouns, renamed)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateUsername
+		}
+
+		return err
+	})
+}
+
+// SetAvatar replaces the avatar of a user and returns the blob key of the
+// previous one, if any, so that it can be removed. An empty key and url
+// clear the avatar.
+func (s *UserStore) SetAvatar(ctx context.Context, userID int64, key, url string) (string, error) {
+	query := `
+		UPDATE users u SET avatar_key = $2, avatar_url = $3
+		FROM (SELECT avatar_key FROM users WHERE id = $1 FOR UPDATE) old
+		WHERE u.id = $1
+		RETURNING old.avatar_key
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var previous string
+	err := s.db.QueryRowContext(ctx, query, userID, key, url).Scan(&previous)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return previous, nil
+}
+
+// SetPrivate switches a user between a private and a public account. Going
+// public approves every pending follow request.
+func (s *UserStore) SetPrivate(ctx context.Context, userID int64, private bool) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `UPDATE users SET is_private = $2 WHERE id = $1`, userID, private)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if private {
+			return nil
+		}
+
+		query := `
+			WITH approved AS (
+				DELETE FROM follow_requests WHERE user_id = $1 RETURNING user_id, follower_id
+			)
+			INSERT INTO followers (user_id, follower_id)
+			SELECT user_id, follower_id FROM approved
+			ON CONFLICT DO NOTHING
+		`
+		_, err = tx.ExecContext(ctx, query, userID)
+
+		return err
+	})
+}
+
 func (s *UserStore) CreateAndInvite(ctx context.Context, user *Users, token string, invitationExp time.Duration) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		if err := s.Create(ctx, tx, user); err != nil {
@@ -209,6 +427,102 @@ func (s *UserStore) deleteUserInvitation(ctx context.Context, tx *sql.Tx, userID
 	return nil
 }
 
+func (s *UserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// only the most recently requested link stays usable
+		if err := s.deletePasswordResets(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO password_resets (token, user_id, expiry) VALUES ($1, $2, $3)`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp))
+
+		return err
+	})
+}
+
+// ResetPassword sets a new password for the owner of a valid reset token,
+// consumes the token and revokes every refresh and access token of the user
+// so that existing sessions end. It returns the id of the user.
+func (s *UserStore) ResetPassword(ctx context.Context, token string, password *Password) (int64, error) {
+	var userID int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		userID, err = s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// tokens carry their issue time in seconds, so the cutoff is rounded
+		// up to reject the ones issued earlier in the second of the reset;
+		// tokens issued later in that second are rejected as well
+		query := `UPDATE users SET password = $1, tokens_valid_after = date_trunc('second', NOW()) + interval '1 second' WHERE id = $2`
+		if _, err := tx.ExecContext(ctx, query, password.hash, userID); err != nil {
+			return err
+		}
+
+		if err := s.deletePasswordResets(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		query = `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	return userID, err
+}
+
+func (s *UserStore) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (int64, error) {
+	query := `
+		SELECT pr.user_id
+		FROM password_resets pr
+		JOIN users u ON u.id = pr.user_id
+		WHERE pr.token = $1 AND pr.expiry > $2 AND u.is_active = true AND u.deleted_at IS NULL
+		FOR UPDATE OF pr
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(token))
+	hashedToken := hex.EncodeToString(hash[:])
+
+	var userID int64
+	err := tx.QueryRowContext(ctx, query, hashedToken, time.Now()).Scan(&userID)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+func (s *UserStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM password_resets WHERE user_id = $1`
+
+	_, err := tx.ExecContext(ctx, query, userID)
+
+	return err
+}
+
+// Delete removes a user that never got activated, together with its
+// invitation. Accounts in use are soft deleted with SoftDelete instead.
 func (s *UserStore) Delete(ctx context.Context, userID int64) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		if err := s.deleteUser(ctx, tx, userID); err != nil {
@@ -242,7 +556,7 @@ func (s *UserStore) deleteUser(ctx context.Context, tx *sql.Tx, userId int64) er
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*Users, error) {
 	query := `
 		SELECT id, username, email, password, created_at, is_active
-	 	FROM users WHERE email = $1 AND is_active = true
+	 	FROM users WHERE email = $1 AND is_active = true AND deleted_at IS NULL
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -252,9 +566,96 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*Users, error
 	err := s.db.QueryRowContext(ctx, query, email).Scan(&users.ID, &users.Username, &users.Email, &users.Password.hash, &users.CreatedAt, &users.IsActive)
 
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
 	}
 
 	return users, nil
 
 }
+
+// SoftDelete deletes an account. The user can no longer sign in and is left
+// out of every query, and the account can be restored until it is purged.
+// Its refresh tokens are revoked, so that its sessions cannot be renewed.
+func (s *UserStore) SoftDelete(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE users SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		query = `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Restore undoes the deletion of an account deleted less than window ago.
+func (s *UserStore) Restore(ctx context.Context, userID int64, window time.Duration) error {
+	query := `
+		UPDATE users SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at >= NOW() - $2 * interval '1 second'
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, int64(window.Seconds()))
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Purge removes the accounts deleted more than retention ago for good,
+// along with their posts, comments, reactions, follows and media, and
+// returns the keys of the blobs of their avatars and media.
+func (s *UserStore) Purge(ctx context.Context, retention time.Duration) ([]string, error) {
+	query := `
+		WITH purged AS (
+			DELETE FROM users WHERE deleted_at < NOW() - $1 * interval '1 second'
+			RETURNING id, avatar_key
+		)
+		SELECT avatar_key FROM purged WHERE avatar_key <> ''
+		UNION ALL
+		SELECT unnest(ARRAY[m.key, m.thumbnail_key])
+		FROM media m
+		JOIN purged ON purged.id = m.user_id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return queryStrings(ctx, s.db, query, int64(retention.Seconds()))
+}
@@ -0,0 +1,58 @@
+package store
+
+// Post visibility levels. The visibility of a post narrows down who may see
+// it on top of the privacy of its author's account.
+const (
+	// VisibilityPublic posts are visible to anyone who may see the author's
+	// posts: everyone for public accounts, followers for private ones.
+	VisibilityPublic = "public"
+	// VisibilityFollowers posts are visible to the author's followers.
+	VisibilityFollowers = "followers"
+	// VisibilityMentioned posts are visible to the users they mention.
+	VisibilityMentioned = "mentioned"
+	// VisibilityPrivate posts are only visible to their author.
+	VisibilityPrivate = "private"
+)
+
+// Post statuses. Drafts and scheduled posts are only visible to their
+// author until they are published.
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+)
+
+// postVisibleTo is a condition on the post aliased post that holds when it
+// is published, live and may be seen by viewer: the viewer wrote it, or its
+// visibility lets the viewer see it. Public posts of private accounts are
+// only visible to the approved followers of the author.
+func postVisibleTo(viewer, post string) string {
+	follows := `EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = ` + post + `.user_id AND vf.follower_id = ` + viewer + `)`
+
+	return `(
+			` + post + `.status = 'published' AND ` + postLive(post) + ` AND (
+			` + post + `.user_id = ` + viewer + ` OR
+			(` + post + `.visibility = 'public' AND (
+				NOT EXISTS (SELECT 1 FROM users vu WHERE vu.id = ` + post + `.user_id AND vu.is_private) OR
+				` + follows + `
+			)) OR
+			(` + post + `.visibility = 'followers' AND ` + follows + `) OR
+			(` + post + `.visibility = 'mentioned' AND EXISTS (
+				SELECT 1 FROM mentions vm WHERE vm.post_id = ` + post + `.id AND vm.user_id = ` + viewer + `
+			))
+		))`
+}
+
+// postReadableBy is postVisibleTo extended to the drafts and scheduled posts
+// of viewer, which authors may open directly but which never reach a feed.
+func postReadableBy(viewer, post string) string {
+	return `((` + post + `.user_id = ` + viewer + ` AND ` + post + `.deleted_at IS NULL) OR ` + postVisibleTo(viewer, post) + `)`
+}
+
+// postLive is a condition on the post aliased post that holds when neither
+// the post nor its author was deleted.
+func postLive(post string) string {
+	return `(` + post + `.deleted_at IS NULL AND NOT EXISTS (
+				SELECT 1 FROM users du WHERE du.id = ` + post + `.user_id AND du.deleted_at IS NOT NULL
+			))`
+}
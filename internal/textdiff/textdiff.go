@@ -0,0 +1,139 @@
+// Package textdiff computes word level differences between two texts.
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kind tells how a piece of text changed.
+type Kind string
+
+const (
+	Equal  Kind = "equal"
+	Insert Kind = "insert"
+	Delete Kind = "delete"
+)
+
+// Op is a piece of text that is kept, inserted or deleted.
+type Op struct {
+	Kind Kind   `json:"kind"`
+	Text string `json:"text"`
+}
+
+// maxCells bounds the size of the table used to align two texts. Texts that
+// differ in more words than that are reported as replaced as a whole.
+const maxCells = 1 << 20
+
+// Diff returns the operations that turn a into b. Texts are compared word
+// by word, with runs of whitespace counting as words, and adjacent
+// operations of the same kind are merged.
+func Diff(a, b string) []Op {
+	x, y := tokenize(a), tokenize(b)
+
+	// the common prefix and suffix do not need to be aligned
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	d := &differ{}
+	d.add(Equal, x[:prefix]...)
+	d.align(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])
+	d.add(Equal, x[len(x)-suffix:]...)
+
+	return d.ops
+}
+
+type differ struct {
+	ops []Op
+}
+
+func (d *differ) add(kind Kind, tokens ...string) {
+	if len(tokens) == 0 {
+		return
+	}
+
+	text := strings.Join(tokens, "")
+	if n := len(d.ops); n > 0 && d.ops[n-1].Kind == kind {
+		d.ops[n-1].Text += text
+		return
+	}
+
+	d.ops = append(d.ops, Op{Kind: kind, Text: text})
+}
+
+// align adds the operations turning x into y, keeping their longest common
+// subsequence of words.
+func (d *differ) align(x, y []string) {
+	if len(x)*len(y) > maxCells {
+		d.add(Delete, x...)
+		d.add(Insert, y...)
+		return
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:]
+	lcs := make([][]int32, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(y)+1)
+	}
+
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			d.add(Equal, x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			d.add(Delete, x[i])
+			i++
+		default:
+			d.add(Insert, y[j])
+			j++
+		}
+	}
+
+	d.add(Delete, x[i:]...)
+	d.add(Insert, y[j:]...)
+}
+
+// tokenize splits s into alternating runs of whitespace and of other
+// characters.
+func tokenize(s string) []string {
+	var tokens []string
+
+	start, space := 0, false
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != space {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+
+	return tokens
+}
@@ -0,0 +1,36 @@
+package textdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []Op
+	}{
+		{"", "", nil},
+		{"same text", "same text", []Op{{Equal, "same text"}}},
+		{"", "new", []Op{{Insert, "new"}}},
+		{"old", "", []Op{{Delete, "old"}}},
+		{
+			"the quick brown fox", "the slow brown fox",
+			[]Op{{Equal, "the "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " brown fox"}},
+		},
+		{
+			"hello world", "hello big world",
+			[]Op{{Equal, "hello "}, {Insert, "big "}, {Equal, "world"}},
+		},
+		{
+			"café au lait", "café lait",
+			[]Op{{Equal, "café "}, {Delete, "au "}, {Equal, "lait"}},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Diff(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Diff(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
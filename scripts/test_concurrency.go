@@ -5,15 +5,43 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 )
 
+// token authenticates the requests as the author of the post. Get one from
+// POST /v1/authentication/token and export it as API_TOKEN.
+var token = os.Getenv("API_TOKEN")
+
 type UpdatePostPayload struct {
 	Title   *string `json:"title" `
 	Content *string `json:"content"`
 }
 
-func updatePost(postID int, p UpdatePostPayload, wg *sync.WaitGroup) {
+// getETag fetches the post and returns the ETag of its current version.
+func getETag(postID int) (string, error) {
+	url := fmt.Sprintf("http://localhost:5050/v1/posts/%d", postID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return resp.Header.Get("ETag"), nil
+}
+
+func updatePost(postID int, etag string, p UpdatePostPayload, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	// Construct the URL for the update endpoint
@@ -30,6 +58,10 @@ func updatePost(postID int, p UpdatePostPayload, wg *sync.WaitGroup) {
 
 	// Set headers as needed, for example:
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	// both updates were made to the same version, so only the first one to
+	// arrive is applied and the other gets 412 Precondition Failed
+	req.Header.Set("If-Match", etag)
 
 	// Send the request
 	client := &http.Client{}
@@ -44,17 +76,28 @@ func updatePost(postID int, p UpdatePostPayload, wg *sync.WaitGroup) {
 }
 
 func main() {
+	if token == "" {
+		fmt.Println("API_TOKEN is not set")
+		return
+	}
+
 	var wg sync.WaitGroup
 
 	// Assuming the post ID to update is 1
 	postID := 12
 
+	etag, err := getETag(postID)
+	if err != nil {
+		fmt.Println("Error fetching post:", err)
+		return
+	}
+
 	// Simulate User A and User B updating the same post concurrently
 	wg.Add(2)
 	content := "NEW CONTENT FROM USER B"
 	title := "NEW TITLE FROM USER A"
 
-	go updatePost(postID, UpdatePostPayload{Title: &title}, &wg)
-	go updatePost(postID, UpdatePostPayload{Content: &content}, &wg)
+	go updatePost(postID, etag, UpdatePostPayload{Title: &title}, &wg)
+	go updatePost(postID, etag, UpdatePostPayload{Content: &content}, &wg)
 	wg.Wait()
 }
\ No newline at end of file